@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 )
@@ -23,6 +25,9 @@ const (
 	VUnknown VersionKey = "Unknown"
 )
 
+// Versions are the versions of the api served by the service, oldest first
+var Versions = []VersionKey{V1, V2, V3}
+
 // String casts a VersionKey to string
 func (v VersionKey) String() string {
 	return string(v)
@@ -42,6 +47,47 @@ func VersionKeyFromString(key string) VersionKey {
 	return VUnknown
 }
 
+// Deprecation describes a deprecated version of the api
+type Deprecation struct {
+	// Sunset is the date the version stops being served, zero when no date
+	// has been set
+	Sunset time.Time
+}
+
+// BackendKey supports a type safe string discriminator for the database
+// backend storing the coffees.
+type BackendKey string
+
+const (
+	// Postgres stores the data in a Postgres database
+	Postgres BackendKey = "postgres"
+	// Memory stores the data in an in memory go-memdb database
+	Memory BackendKey = "memory"
+	// SQLite stores the data in a SQLite database file
+	SQLite BackendKey = "sqlite"
+	// BackendUnknown indicates the backend cannot be resolved
+	BackendUnknown BackendKey = "Unknown"
+)
+
+// String casts a BackendKey to string
+func (b BackendKey) String() string {
+	return string(b)
+}
+
+// BackendKeyFromString casts a string to a BackendKey
+func BackendKeyFromString(key string) BackendKey {
+	switch key {
+	case Postgres.String():
+		return Postgres
+	case Memory.String():
+		return Memory
+	case SQLite.String():
+		return SQLite
+	}
+
+	return BackendUnknown
+}
+
 // EnvVarKey supports a type safe string discriminator for environment variables.
 type EnvVarKey string
 
@@ -69,6 +115,74 @@ func EnvVarKeyFromString(key string) EnvVarKey {
 		return DBTraceEnabled
 	case Version.String():
 		return Version
+	case SQLitePath.String():
+		return SQLitePath
+	case DBBackend.String():
+		return DBBackend
+	case DeprecatedVersions.String():
+		return DeprecatedVersions
+	case TracingEndpoint.String():
+		return TracingEndpoint
+	case TracingSampleRatio.String():
+		return TracingSampleRatio
+	case ServiceName.String():
+		return ServiceName
+	case HealthCheckTimeout.String():
+		return HealthCheckTimeout
+	case ReadTimeout.String():
+		return ReadTimeout
+	case WriteTimeout.String():
+		return WriteTimeout
+	case IdleTimeout.String():
+		return IdleTimeout
+	case ShutdownDelay.String():
+		return ShutdownDelay
+	case ShutdownGracePeriod.String():
+		return ShutdownGracePeriod
+	case DBConnectInitialBackoff.String():
+		return DBConnectInitialBackoff
+	case DBConnectMaxBackoff.String():
+		return DBConnectMaxBackoff
+	case DBConnectMaxWait.String():
+		return DBConnectMaxWait
+	case DBConnectDegraded.String():
+		return DBConnectDegraded
+	case CircuitBreakerFailureThreshold.String():
+		return CircuitBreakerFailureThreshold
+	case CircuitBreakerCoolDown.String():
+		return CircuitBreakerCoolDown
+	case CircuitBreakerFallback.String():
+		return CircuitBreakerFallback
+	case DBHost.String():
+		return DBHost
+	case DBPort.String():
+		return DBPort
+	case DBName.String():
+		return DBName
+	case DBSSLMode.String():
+		return DBSSLMode
+	case DBSSLRootCert.String():
+		return DBSSLRootCert
+	case DBConnectionString.String():
+		return DBConnectionString
+	case DBMaxOpenConns.String():
+		return DBMaxOpenConns
+	case DBMaxIdleConns.String():
+		return DBMaxIdleConns
+	case DBConnMaxLifetime.String():
+		return DBConnMaxLifetime
+	case MigrateOnStart.String():
+		return MigrateOnStart
+	case SeedFile.String():
+		return SeedFile
+	case PurgeRetention.String():
+		return PurgeRetention
+	case PurgeInterval.String():
+		return PurgeInterval
+	case CacheControlCoffees.String():
+		return CacheControlCoffees
+	case CacheControlIngredients.String():
+		return CacheControlIngredients
 	}
 
 	return Unknown
@@ -91,6 +205,74 @@ const (
 	DBTraceEnabled EnvVarKey = "DB_TRACE_ENABLED"
 	// Version EnvVarKey
 	Version EnvVarKey = "VERSION"
+	// SQLitePath EnvVarKey
+	SQLitePath EnvVarKey = "SQLITE_PATH"
+	// DBBackend EnvVarKey
+	DBBackend EnvVarKey = "DB_BACKEND"
+	// DeprecatedVersions EnvVarKey
+	DeprecatedVersions EnvVarKey = "DEPRECATED_VERSIONS"
+	// TracingEndpoint EnvVarKey
+	TracingEndpoint EnvVarKey = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	// TracingSampleRatio EnvVarKey
+	TracingSampleRatio EnvVarKey = "OTEL_TRACES_SAMPLER_ARG"
+	// ServiceName EnvVarKey
+	ServiceName EnvVarKey = "OTEL_SERVICE_NAME"
+	// HealthCheckTimeout EnvVarKey
+	HealthCheckTimeout EnvVarKey = "HEALTH_CHECK_TIMEOUT"
+	// ReadTimeout EnvVarKey
+	ReadTimeout EnvVarKey = "HTTP_READ_TIMEOUT"
+	// WriteTimeout EnvVarKey
+	WriteTimeout EnvVarKey = "HTTP_WRITE_TIMEOUT"
+	// IdleTimeout EnvVarKey
+	IdleTimeout EnvVarKey = "HTTP_IDLE_TIMEOUT"
+	// ShutdownDelay EnvVarKey
+	ShutdownDelay EnvVarKey = "SHUTDOWN_DELAY"
+	// ShutdownGracePeriod EnvVarKey
+	ShutdownGracePeriod EnvVarKey = "SHUTDOWN_GRACE_PERIOD"
+	// DBConnectInitialBackoff EnvVarKey
+	DBConnectInitialBackoff EnvVarKey = "DB_CONNECT_INITIAL_BACKOFF"
+	// DBConnectMaxBackoff EnvVarKey
+	DBConnectMaxBackoff EnvVarKey = "DB_CONNECT_MAX_BACKOFF"
+	// DBConnectMaxWait EnvVarKey
+	DBConnectMaxWait EnvVarKey = "DB_CONNECT_MAX_WAIT"
+	// DBConnectDegraded EnvVarKey
+	DBConnectDegraded EnvVarKey = "DB_CONNECT_DEGRADED"
+	// CircuitBreakerFailureThreshold EnvVarKey
+	CircuitBreakerFailureThreshold EnvVarKey = "CIRCUIT_BREAKER_FAILURE_THRESHOLD"
+	// CircuitBreakerCoolDown EnvVarKey
+	CircuitBreakerCoolDown EnvVarKey = "CIRCUIT_BREAKER_COOL_DOWN"
+	// CircuitBreakerFallback EnvVarKey
+	CircuitBreakerFallback EnvVarKey = "CIRCUIT_BREAKER_FALLBACK"
+	// DBHost EnvVarKey
+	DBHost EnvVarKey = "DB_HOST"
+	// DBPort EnvVarKey
+	DBPort EnvVarKey = "DB_PORT"
+	// DBName EnvVarKey
+	DBName EnvVarKey = "DB_NAME"
+	// DBSSLMode EnvVarKey
+	DBSSLMode EnvVarKey = "DB_SSLMODE"
+	// DBSSLRootCert EnvVarKey
+	DBSSLRootCert EnvVarKey = "DB_SSLROOTCERT"
+	// DBConnectionString EnvVarKey
+	DBConnectionString EnvVarKey = "DB_CONNECTION_STRING"
+	// DBMaxOpenConns EnvVarKey
+	DBMaxOpenConns EnvVarKey = "DB_MAX_OPEN_CONNS"
+	// DBMaxIdleConns EnvVarKey
+	DBMaxIdleConns EnvVarKey = "DB_MAX_IDLE_CONNS"
+	// DBConnMaxLifetime EnvVarKey
+	DBConnMaxLifetime EnvVarKey = "DB_CONN_MAX_LIFETIME"
+	// MigrateOnStart EnvVarKey
+	MigrateOnStart EnvVarKey = "MIGRATE_ON_START"
+	// SeedFile EnvVarKey
+	SeedFile EnvVarKey = "SEED_FILE"
+	// PurgeRetention EnvVarKey
+	PurgeRetention EnvVarKey = "PURGE_RETENTION"
+	// PurgeInterval EnvVarKey
+	PurgeInterval EnvVarKey = "PURGE_INTERVAL"
+	// CacheControlCoffees EnvVarKey
+	CacheControlCoffees EnvVarKey = "CACHE_CONTROL_COFFEES"
+	// CacheControlIngredients EnvVarKey
+	CacheControlIngredients EnvVarKey = "CACHE_CONTROL_INGREDIENTS"
 	// Unknown EnvVarKey
 	Unknown EnvVarKey = "UNKNOWN"
 )
@@ -98,19 +280,159 @@ const (
 // Config defines the service runtime configuration
 type Config struct {
 	ConnectionString string
+	Postgres         PostgresDB
 	BindAddress      string
 	MetricsAddress   string
-	DBTraceEnabled   bool
-	Logger           hclog.Logger
-	Version          VersionKey
+	// DBTraceEnabled is deprecated, the repository queries are always traced
+	DBTraceEnabled     bool
+	Logger             hclog.Logger
+	Version            VersionKey
+	Deprecations       map[VersionKey]Deprecation
+	DBBackend          BackendKey
+	SQLitePath         string
+	Tracing            Tracing
+	HealthCheckTimeout time.Duration
+	Server             Server
+	DBConnect          DBConnect
+	CircuitBreaker     CircuitBreaker
+	// MigrateOnStart applies the pending schema migrations when the service
+	// connects to Postgres
+	MigrateOnStart bool
+	// SeedFile is the JSON or YAML file of the menu loaded into empty
+	// databases, the default menu is loaded when empty
+	SeedFile     string
+	Purge        Purge
+	CacheControl CacheControl
+}
+
+// CacheControl configures the Cache-Control header of the successful GET
+// responses of each group of routes, no header is sent when empty
+type CacheControl struct {
+	// Coffees applies to the coffees routes of every version
+	Coffees string
+	// Ingredients applies to the ingredients routes
+	Ingredients string
+}
+
+// Purge configures the job hard deleting the soft deleted coffees and
+// ingredients
+type Purge struct {
+	// Retention is the time deleted rows can be restored before they are
+	// purged, 0 disables the purge job and keeps them forever
+	Retention time.Duration
+	// Interval is the time between two runs of the purge job
+	Interval time.Duration
+}
+
+// CircuitBreaker configures the circuit breaker of the repository
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit, 0 disables the circuit breaker
+	FailureThreshold int
+	// CoolDown is the time the circuit stays open before a call probes the
+	// database again
+	CoolDown time.Duration
+	// Fallback serves the last successful result of a list of coffees while the
+	// database fails
+	Fallback bool
+}
+
+// DBConnect configures the connection to the database on start
+type DBConnect struct {
+	// InitialBackoff is the delay before the first retry, doubled for each
+	// retry up to MaxBackoff, with a random jitter of up to half the delay
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxWait bounds the time spent connecting before the service gives up
+	MaxWait time.Duration
+	// Degraded starts the service immediately, failing the readiness check and
+	// the requests until a background connection succeeds, instead of waiting
+	// for the database
+	Degraded bool
+}
+
+// Server configures the timeouts of the HTTP server and its shutdown
+type Server struct {
+	// ReadTimeout bounds the time to read a request, including its body
+	ReadTimeout time.Duration
+	// WriteTimeout bounds the time to handle a request and write the response
+	WriteTimeout time.Duration
+	// IdleTimeout bounds the time a keep-alive connection waits for the next
+	// request
+	IdleTimeout time.Duration
+	// ShutdownDelay is the time the readiness check fails before the server
+	// stops accepting connections, so that load balancers stop routing to it
+	ShutdownDelay time.Duration
+	// ShutdownGracePeriod bounds the time the requests in flight have to
+	// complete once the server stops accepting connections
+	ShutdownGracePeriod time.Duration
+}
+
+// Tracing configures the OpenTelemetry tracer provider
+type Tracing struct {
+	// Endpoint is the URL of the OTLP/HTTP collector, spans are not exported
+	// when empty
+	Endpoint string
+	// SampleRatio is the ratio of the traces started by the service that are
+	// sampled, the decision of the caller is respected otherwise
+	SampleRatio float64
+	// ServiceName identifies the service in the traces
+	ServiceName string
+}
+
+// defaultSQLitePath is the database file used by the SQLite backend when
+// SQLITE_PATH is not set
+const defaultSQLitePath = "coffee-service.db"
+
+// defaultServiceName identifies the service in the traces when
+// OTEL_SERVICE_NAME is not set
+const defaultServiceName = "coffee-service"
+
+// defaultHealthCheckTimeout is below the one second timeout of the Kubernetes
+// probes
+const defaultHealthCheckTimeout = 500 * time.Millisecond
+
+// defaultServer leaves the end of the default 30 seconds termination grace
+// period of Kubernetes to close the repository
+var defaultServer = Server{
+	ReadTimeout:         5 * time.Second,
+	WriteTimeout:        10 * time.Second,
+	IdleTimeout:         120 * time.Second,
+	ShutdownDelay:       5 * time.Second,
+	ShutdownGracePeriod: 20 * time.Second,
+}
+
+// defaultCircuitBreaker opens the circuit after 5 consecutive failures
+var defaultCircuitBreaker = CircuitBreaker{
+	FailureThreshold: 5,
+	CoolDown:         10 * time.Second,
+}
+
+// defaultPurge keeps the deleted rows, the purge is opt-in as the database may
+// be shared with other services. Once enabled it runs hourly.
+var defaultPurge = Purge{
+	Retention: 0,
+	Interval:  time.Hour,
+}
+
+// defaultCacheControl lets clients and proxies store the responses, but
+// revalidate them with a conditional GET before every use. Without it caches
+// could heuristically reuse the responses, as they carry a Last-Modified.
+var defaultCacheControl = CacheControl{
+	Coffees:     "no-cache",
+	Ingredients: "no-cache",
+}
+
+// defaultDBConnect waits up to a minute for the database, as the service did
+// before backoff was configurable
+var defaultDBConnect = DBConnect{
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	MaxWait:        60 * time.Second,
 }
 
 // NewFromEnv aggregates the environment variables to a datastructure.
 func NewFromEnv() (*Config, error) {
-	// TODO: error handling
-	username := os.Getenv(Username.String())
-	password := os.Getenv(Password.String())
-	formatString := "host=localhost port=5432 user=%s password=%s dbname=products sslmode=disable"
 	bindAddress := os.Getenv(BindAddress.String())
 	metricsAddress := os.Getenv(MetricsAddress.String())
 	// TODO: Think about moving towards opentelemetry interfaces.
@@ -134,14 +456,223 @@ func NewFromEnv() (*Config, error) {
 			logger.Error(fmt.Sprintf("Unable to parse %s", DBTraceEnabled.String()), "error", err)
 		}
 	}
-	versionKey := VersionKeyFromString(os.Getenv(Version.String()))
+	// Every version is served, VERSION only sets the default
+	versionKey := V1
+	if version := os.Getenv(Version.String()); version != "" {
+		if versionKey = VersionKeyFromString(version); versionKey == VUnknown {
+			return nil, fmt.Errorf("%s must be one of %s, %s or %s, got %q", Version, V1, V2, V3, version)
+		}
+	}
+
+	deprecations, err := parseDeprecations(os.Getenv(DeprecatedVersions.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	// Postgres remains the default backend for existing deployments
+	backendKey := Postgres
+	if backend := os.Getenv(DBBackend.String()); backend != "" {
+		if backendKey = BackendKeyFromString(strings.ToLower(backend)); backendKey == BackendUnknown {
+			return nil, fmt.Errorf("%s must be one of %s, %s or %s, got %q", DBBackend, Postgres, Memory, SQLite, backend)
+		}
+	}
+
+	sqlitePath := os.Getenv(SQLitePath.String())
+	if sqlitePath == "" {
+		sqlitePath = defaultSQLitePath
+	}
+
+	tracing, err := parseTracing(os.Getenv(TracingEndpoint.String()), os.Getenv(TracingSampleRatio.String()), os.Getenv(ServiceName.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	healthCheckTimeout, err := parseDuration(HealthCheckTimeout, defaultHealthCheckTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if healthCheckTimeout == 0 {
+		return nil, fmt.Errorf("%s must be longer than 0", HealthCheckTimeout)
+	}
+
+	server := defaultServer
+	for key, d := range map[EnvVarKey]*time.Duration{
+		ReadTimeout:         &server.ReadTimeout,
+		WriteTimeout:        &server.WriteTimeout,
+		IdleTimeout:         &server.IdleTimeout,
+		ShutdownDelay:       &server.ShutdownDelay,
+		ShutdownGracePeriod: &server.ShutdownGracePeriod,
+	} {
+		if *d, err = parseDuration(key, *d); err != nil {
+			return nil, err
+		}
+	}
+
+	dbConnect := defaultDBConnect
+	for key, d := range map[EnvVarKey]*time.Duration{
+		DBConnectInitialBackoff: &dbConnect.InitialBackoff,
+		DBConnectMaxBackoff:     &dbConnect.MaxBackoff,
+		DBConnectMaxWait:        &dbConnect.MaxWait,
+	} {
+		if *d, err = parseDuration(key, *d); err != nil {
+			return nil, err
+		}
+	}
+	if dbConnect.InitialBackoff == 0 || dbConnect.MaxBackoff < dbConnect.InitialBackoff {
+		return nil, fmt.Errorf("%s must be longer than 0 and not exceed %s", DBConnectInitialBackoff, DBConnectMaxBackoff)
+	}
+	if degraded := os.Getenv(DBConnectDegraded.String()); degraded != "" {
+		if dbConnect.Degraded, err = strconv.ParseBool(degraded); err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", DBConnectDegraded, degraded)
+		}
+	}
+
+	circuitBreaker := defaultCircuitBreaker
+	if threshold := os.Getenv(CircuitBreakerFailureThreshold.String()); threshold != "" {
+		if circuitBreaker.FailureThreshold, err = strconv.Atoi(threshold); err != nil || circuitBreaker.FailureThreshold < 0 {
+			return nil, fmt.Errorf("%s must be a positive number, or 0 to disable the circuit breaker, got %q", CircuitBreakerFailureThreshold, threshold)
+		}
+	}
+	if circuitBreaker.CoolDown, err = parseDuration(CircuitBreakerCoolDown, circuitBreaker.CoolDown); err != nil {
+		return nil, err
+	}
+	if fallback := os.Getenv(CircuitBreakerFallback.String()); fallback != "" {
+		if circuitBreaker.Fallback, err = strconv.ParseBool(fallback); err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", CircuitBreakerFallback, fallback)
+		}
+	}
+
+	postgres, err := parsePostgres()
+	if err != nil {
+		return nil, err
+	}
+
+	migrateOnStart := false
+	if migrate := os.Getenv(MigrateOnStart.String()); migrate != "" {
+		if migrateOnStart, err = strconv.ParseBool(migrate); err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", MigrateOnStart, migrate)
+		}
+	}
+
+	purge := defaultPurge
+	for key, d := range map[EnvVarKey]*time.Duration{
+		PurgeRetention: &purge.Retention,
+		PurgeInterval:  &purge.Interval,
+	} {
+		if *d, err = parseDuration(key, *d); err != nil {
+			return nil, err
+		}
+	}
+	if purge.Interval == 0 {
+		return nil, fmt.Errorf("%s must be longer than 0", PurgeInterval)
+	}
+
+	cacheControl := defaultCacheControl
+	for key, value := range map[EnvVarKey]*string{
+		CacheControlCoffees:     &cacheControl.Coffees,
+		CacheControlIngredients: &cacheControl.Ingredients,
+	} {
+		// An empty value disables the header
+		if v, ok := os.LookupEnv(key.String()); ok {
+			*value = strings.TrimSpace(v)
+		}
+	}
 
 	return &Config{
-		ConnectionString: fmt.Sprintf(formatString, username, password),
-		BindAddress:      bindAddress,
-		MetricsAddress:   metricsAddress,
-		DBTraceEnabled:   dbTraceEnabled,
-		Logger:           logger,
-		Version:          versionKey,
+		ConnectionString:   postgres.DSN(),
+		Postgres:           postgres,
+		BindAddress:        bindAddress,
+		MetricsAddress:     metricsAddress,
+		DBTraceEnabled:     dbTraceEnabled,
+		Logger:             logger,
+		Version:            versionKey,
+		Deprecations:       deprecations,
+		DBBackend:          backendKey,
+		SQLitePath:         sqlitePath,
+		Tracing:            tracing,
+		HealthCheckTimeout: healthCheckTimeout,
+		Server:             server,
+		DBConnect:          dbConnect,
+		CircuitBreaker:     circuitBreaker,
+		MigrateOnStart:     migrateOnStart,
+		SeedFile:           os.Getenv(SeedFile.String()),
+		Purge:              purge,
+		CacheControl:       cacheControl,
 	}, nil
 }
+
+// parseDuration reads the duration of the environment variable, e.g. 500ms or
+// 10s, returning the default when it is not set.
+func parseDuration(key EnvVarKey, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key.String())
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a duration, e.g. 500ms or 10s, got %q", key, value)
+	}
+
+	return d, nil
+}
+
+// parseTracing validates the tracing configuration, every trace is sampled and
+// the service is named coffee-service by default.
+func parseTracing(endpoint, ratio, serviceName string) (Tracing, error) {
+	tracing := Tracing{Endpoint: endpoint, SampleRatio: 1, ServiceName: serviceName}
+
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Tracing{}, fmt.Errorf("%s must be a http or https URL, got %q", TracingEndpoint, endpoint)
+		}
+	}
+
+	if ratio != "" {
+		r, err := strconv.ParseFloat(ratio, 64)
+		if err != nil || r < 0 || r > 1 {
+			return Tracing{}, fmt.Errorf("%s must be a ratio between 0 and 1, got %q", TracingSampleRatio, ratio)
+		}
+		tracing.SampleRatio = r
+	}
+
+	if tracing.ServiceName == "" {
+		tracing.ServiceName = defaultServiceName
+	}
+
+	return tracing, nil
+}
+
+// parseDeprecations parses a comma separated list of deprecated versions, each
+// optionally followed by its sunset date, e.g. "v1=2021-06-30,v2".
+func parseDeprecations(raw string) (map[VersionKey]Deprecation, error) {
+	deprecations := map[VersionKey]Deprecation{}
+
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+
+		version := VersionKeyFromString(parts[0])
+		if version == VUnknown {
+			return nil, fmt.Errorf("%s contains unknown version %q", DeprecatedVersions, parts[0])
+		}
+
+		deprecation := Deprecation{}
+		if len(parts) == 2 {
+			sunset, err := time.Parse("2006-01-02", parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s sunset date of %s must be formatted as YYYY-MM-DD, got %q", DeprecatedVersions, version, parts[1])
+			}
+			deprecation.Sunset = sunset
+		}
+
+		deprecations[version] = deprecation
+	}
+
+	return deprecations, nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeprecationsReadsVersionsAndSunsets(t *testing.T) {
+	deprecations, err := parseDeprecations("v1=2021-06-30, v2")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[VersionKey]Deprecation{
+		V1: {Sunset: time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC)},
+		V2: {},
+	}, deprecations)
+}
+
+func TestParseDeprecationsAllowsEmptyList(t *testing.T) {
+	deprecations, err := parseDeprecations("")
+	require.NoError(t, err)
+	assert.Empty(t, deprecations)
+}
+
+func TestParseDeprecationsRejectsUnknownVersion(t *testing.T) {
+	_, err := parseDeprecations("v9")
+	assert.Error(t, err)
+}
+
+func TestParseDeprecationsRejectsInvalidSunset(t *testing.T) {
+	_, err := parseDeprecations("v1=30/06/2021")
+	assert.Error(t, err)
+}
+
+func TestParseTracingDefaults(t *testing.T) {
+	tracing, err := parseTracing("", "", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, Tracing{SampleRatio: 1, ServiceName: "coffee-service"}, tracing)
+}
+
+func TestParseTracingReadsEndpointAndRatio(t *testing.T) {
+	tracing, err := parseTracing("http://collector:4318", "0.25", "coffee-v3")
+	require.NoError(t, err)
+
+	assert.Equal(t, Tracing{Endpoint: "http://collector:4318", SampleRatio: 0.25, ServiceName: "coffee-v3"}, tracing)
+}
+
+func TestParseTracingRejectsInvalidRatio(t *testing.T) {
+	_, err := parseTracing("", "1.5", "")
+	assert.Error(t, err)
+}
+
+func TestParseTracingRejectsEndpointWithoutScheme(t *testing.T) {
+	_, err := parseTracing("collector:4318", "", "")
+	assert.Error(t, err)
+}
+
+func TestParseDurationReturnsDefaultWhenUnset(t *testing.T) {
+	os.Unsetenv(ShutdownGracePeriod.String())
+
+	d, err := parseDuration(ShutdownGracePeriod, 20*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Second, d)
+}
+
+func TestParseDurationReadsEnvironment(t *testing.T) {
+	os.Setenv(ShutdownGracePeriod.String(), "45s")
+	defer os.Unsetenv(ShutdownGracePeriod.String())
+
+	d, err := parseDuration(ShutdownGracePeriod, 20*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 45*time.Second, d)
+}
+
+func TestParseDurationRejectsInvalidDuration(t *testing.T) {
+	os.Setenv(ShutdownGracePeriod.String(), "45")
+	defer os.Unsetenv(ShutdownGracePeriod.String())
+
+	_, err := parseDuration(ShutdownGracePeriod, 20*time.Second)
+	assert.Error(t, err)
+}
+
+func TestPurgeIsDisabledByDefault(t *testing.T) {
+	os.Unsetenv(PurgeRetention.String())
+
+	retention, err := parseDuration(PurgeRetention, defaultPurge.Retention)
+	require.NoError(t, err)
+	assert.Zero(t, retention)
+}
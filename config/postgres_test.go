@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setEnv sets the environment variables for the duration of the test
+func setEnv(t *testing.T, env map[EnvVarKey]string) {
+	for key, value := range env {
+		previous, ok := os.LookupEnv(key.String())
+		os.Setenv(key.String(), value)
+
+		key := key
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key.String(), previous)
+			} else {
+				os.Unsetenv(key.String())
+			}
+		})
+	}
+}
+
+func TestParsePostgresDefaults(t *testing.T) {
+	setEnv(t, map[EnvVarKey]string{Username: "postgres", Password: "password"})
+
+	pg, err := parsePostgres()
+	require.NoError(t, err)
+
+	assert.Equal(t, "host=localhost port=5432 user=postgres password=password dbname=products sslmode=disable", pg.DSN())
+	assert.Equal(t, DBPool{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: 30 * time.Minute}, pg.Pool)
+}
+
+func TestParsePostgresReadsEnvironment(t *testing.T) {
+	cert := filepath.Join(t.TempDir(), "rds-ca.pem")
+	require.NoError(t, os.WriteFile(cert, []byte("cert"), 0600))
+	setEnv(t, map[EnvVarKey]string{
+		Username:          "coffee",
+		Password:          "it's secret",
+		DBHost:            "db.example.rds.amazonaws.com",
+		DBPort:            "5433",
+		DBName:            "coffees",
+		DBSSLMode:         "verify-full",
+		DBSSLRootCert:     cert,
+		DBMaxOpenConns:    "20",
+		DBMaxIdleConns:    "0",
+		DBConnMaxLifetime: "5m",
+	})
+
+	pg, err := parsePostgres()
+	require.NoError(t, err)
+
+	assert.Equal(t, `host=db.example.rds.amazonaws.com port=5433 user=coffee password='it\'s secret' dbname=coffees sslmode=verify-full sslrootcert=`+cert, pg.DSN())
+	assert.Equal(t, DBPool{MaxOpenConns: 20, MaxIdleConns: 0, ConnMaxLifetime: 5 * time.Minute}, pg.Pool)
+}
+
+func TestParsePostgresConnectionStringOverrides(t *testing.T) {
+	setEnv(t, map[EnvVarKey]string{DBHost: "ignored", DBConnectionString: "postgres://coffee:secret@db:5432/products?sslmode=require"})
+
+	pg, err := parsePostgres()
+	require.NoError(t, err)
+
+	assert.Equal(t, "postgres://coffee:secret@db:5432/products?sslmode=require", pg.DSN())
+}
+
+func TestParsePostgresRejectsInvalidSettings(t *testing.T) {
+	cases := map[EnvVarKey]string{
+		DBPort:             "70000",
+		DBSSLMode:          "prefer",
+		DBSSLRootCert:      "/does/not/exist.pem",
+		DBConnectionString: "db.example.com",
+		DBMaxOpenConns:     "-1",
+		DBConnMaxLifetime:  "forever",
+	}
+
+	for key, value := range cases {
+		t.Run(key.String(), func(t *testing.T) {
+			setEnv(t, map[EnvVarKey]string{key: value})
+
+			_, err := parsePostgres()
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState int32
+
+const (
+	// BreakerClosed lets every call through to the database
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every call fast, without calling the database
+	BreakerOpen
+	// BreakerHalfOpen lets a single call probe the database once the cool-down
+	// elapsed, closing the circuit when it succeeds
+	BreakerHalfOpen
+)
+
+// String returns the name of the state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// maxFallbackPages bounds the number of queries the last result is kept for
+const maxFallbackPages = 128
+
+// CircuitOpenError is returned, without calling the database, while the
+// circuit is open. It is an ErrUnavailable.
+type CircuitOpenError struct {
+	// RetryAfter is the time left before the database is probed again
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open, retry in %s", e.RetryAfter)
+}
+
+// Is reports the error as ErrUnavailable
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+// CircuitBreaker is a Repository decorator failing fast once the database
+// failed FailureThreshold times in a row, so that requests do not pile up on a
+// slow or unavailable database. After the cool-down a single call probes the
+// database, closing the circuit again when it succeeds.
+type CircuitBreaker struct {
+	next     Repository
+	config   config.CircuitBreaker
+	clock    clock
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// probing is set while the probe of the half-open circuit is in flight
+	probing bool
+	// pages holds the last successful result of Find by query for the fallback
+	pages map[string]*CoffeePage
+}
+
+// NewCircuitBreaker wraps the repository with a circuit breaker
+func NewCircuitBreaker(next Repository, cfg config.CircuitBreaker) *CircuitBreaker {
+	return &CircuitBreaker{next: next, config: cfg, clock: realClock{}, pages: map[string]*CoffeePage{}}
+}
+
+// State returns the current state of the circuit
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// allow returns a CircuitOpenError when the call must fail fast. Once the
+// cool-down elapsed the circuit becomes half-open and the call is the probe,
+// as is the next call when a probe was cancelled.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		elapsed := b.clock.Now().Sub(b.openedAt)
+		if elapsed < b.config.CoolDown {
+			return &CircuitOpenError{RetryAfter: b.config.CoolDown - elapsed}
+		}
+		b.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{RetryAfter: b.config.CoolDown}
+		}
+	}
+
+	b.probing = b.state == BreakerHalfOpen
+	return nil
+}
+
+// record updates the state with the result of a call. Errors describing the
+// request, and requests cancelled by the client, are not failures of the
+// database. The driver may not wrap context.Canceled, lib/pq returns the
+// query_canceled error of Postgres, so the context of the call tells whether
+// it was cancelled. A cancelled probe tells nothing about the database
+// either, the circuit stays half-open for the next call to probe it.
+func (b *CircuitBreaker) record(ctx context.Context, err error) {
+	cancelled := err != nil && (errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled))
+	failed := err != nil && !IsRequestError(err) && !cancelled
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.config.FailureThreshold {
+			b.open()
+		}
+	case BreakerHalfOpen:
+		b.probing = false
+
+		if cancelled {
+			return
+		}
+
+		if failed {
+			b.open()
+			return
+		}
+
+		b.state = BreakerClosed
+		b.failures = 0
+	}
+}
+
+// open opens the circuit, the caller holds the lock
+func (b *CircuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.clock.Now()
+}
+
+// call runs f unless the circuit is open
+func (b *CircuitBreaker) call(ctx context.Context, f func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := f()
+	b.record(ctx, err)
+
+	return err
+}
+
+// Find implements Repository. With the fallback, the last successful page of
+// the query is returned, marked stale, while the database fails.
+func (b *CircuitBreaker) Find(ctx context.Context, query CoffeeQuery) (page *CoffeePage, err error) {
+	err = b.call(ctx, func() error {
+		page, err = b.next.Find(ctx, query)
+		return err
+	})
+
+	if !b.config.Fallback || IsRequestError(err) {
+		return page, err
+	}
+
+	key := query.key()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if _, ok := b.pages[key]; !ok && len(b.pages) >= maxFallbackPages {
+			// Make room by forgetting any other query
+			for k := range b.pages {
+				delete(b.pages, k)
+				break
+			}
+		}
+		b.pages[key] = page
+		return page, nil
+	}
+
+	if last, ok := b.pages[key]; ok {
+		stale := *last
+		stale.Stale = true
+		return &stale, nil
+	}
+
+	return nil, err
+}
+
+// FindByID implements Repository
+func (b *CircuitBreaker) FindByID(ctx context.Context, id int, includeDeleted bool) (coffee *entities.Coffee, err error) {
+	err = b.call(ctx, func() error {
+		coffee, err = b.next.FindByID(ctx, id, includeDeleted)
+		return err
+	})
+	return coffee, err
+}
+
+// FindIngredients implements Repository
+func (b *CircuitBreaker) FindIngredients(ctx context.Context, coffeeID int) (ingredients entities.Ingredients, err error) {
+	err = b.call(ctx, func() error {
+		ingredients, err = b.next.FindIngredients(ctx, coffeeID)
+		return err
+	})
+	return ingredients, err
+}
+
+// CreateCoffee implements Repository
+func (b *CircuitBreaker) CreateCoffee(ctx context.Context, coffee entities.Coffee) (created *entities.Coffee, err error) {
+	err = b.call(ctx, func() error {
+		created, err = b.next.CreateCoffee(ctx, coffee)
+		return err
+	})
+	return created, err
+}
+
+// UpdateCoffee implements Repository
+func (b *CircuitBreaker) UpdateCoffee(ctx context.Context, coffee entities.Coffee) (updated *entities.Coffee, err error) {
+	err = b.call(ctx, func() error {
+		updated, err = b.next.UpdateCoffee(ctx, coffee)
+		return err
+	})
+	return updated, err
+}
+
+// DeleteCoffee implements Repository
+func (b *CircuitBreaker) DeleteCoffee(ctx context.Context, id int) error {
+	return b.call(ctx, func() error {
+		return b.next.DeleteCoffee(ctx, id)
+	})
+}
+
+// RestoreCoffee implements Repository
+func (b *CircuitBreaker) RestoreCoffee(ctx context.Context, id int) (coffee *entities.Coffee, err error) {
+	err = b.call(ctx, func() error {
+		coffee, err = b.next.RestoreCoffee(ctx, id)
+		return err
+	})
+	return coffee, err
+}
+
+// Purge implements Repository
+func (b *CircuitBreaker) Purge(ctx context.Context, before time.Time) (purged int, err error) {
+	err = b.call(ctx, func() error {
+		purged, err = b.next.Purge(ctx, before)
+		return err
+	})
+	return purged, err
+}
+
+// Revision implements Repository
+func (b *CircuitBreaker) Revision(ctx context.Context) (revision time.Time, err error) {
+	err = b.call(ctx, func() error {
+		revision, err = b.next.Revision(ctx)
+		return err
+	})
+	return revision, err
+}
+
+// ListIngredients implements Repository
+func (b *CircuitBreaker) ListIngredients(ctx context.Context, query IngredientQuery) (ingredients entities.Ingredients, err error) {
+	err = b.call(ctx, func() error {
+		ingredients, err = b.next.ListIngredients(ctx, query)
+		return err
+	})
+	return ingredients, err
+}
+
+// FindIngredientByID implements Repository
+func (b *CircuitBreaker) FindIngredientByID(ctx context.Context, id int, includeDeleted bool) (ingredient *entities.Ingredient, err error) {
+	err = b.call(ctx, func() error {
+		ingredient, err = b.next.FindIngredientByID(ctx, id, includeDeleted)
+		return err
+	})
+	return ingredient, err
+}
+
+// CreateIngredient implements Repository
+func (b *CircuitBreaker) CreateIngredient(ctx context.Context, ingredient entities.Ingredient) (created *entities.Ingredient, err error) {
+	err = b.call(ctx, func() error {
+		created, err = b.next.CreateIngredient(ctx, ingredient)
+		return err
+	})
+	return created, err
+}
+
+// UpdateIngredient implements Repository
+func (b *CircuitBreaker) UpdateIngredient(ctx context.Context, ingredient entities.Ingredient) (updated *entities.Ingredient, err error) {
+	err = b.call(ctx, func() error {
+		updated, err = b.next.UpdateIngredient(ctx, ingredient)
+		return err
+	})
+	return updated, err
+}
+
+// DeleteIngredient implements Repository
+func (b *CircuitBreaker) DeleteIngredient(ctx context.Context, id int) error {
+	return b.call(ctx, func() error {
+		return b.next.DeleteIngredient(ctx, id)
+	})
+}
+
+// Ping fails fast while the circuit is open. Otherwise the database is pinged
+// without affecting the state, the circuit is only closed by a successful
+// query.
+func (b *CircuitBreaker) Ping(ctx context.Context) error {
+	b.mu.Lock()
+	if b.state == BreakerOpen {
+		if elapsed := b.clock.Now().Sub(b.openedAt); elapsed < b.config.CoolDown {
+			b.mu.Unlock()
+			return &CircuitOpenError{RetryAfter: b.config.CoolDown - elapsed}
+		}
+	}
+	b.mu.Unlock()
+
+	return b.next.Ping(ctx)
+}
+
+// Close implements Repository
+func (b *CircuitBreaker) Close() error {
+	return b.next.Close()
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+var errConnectionReset = errors.New("connection reset by peer")
+
+func setupCircuitBreaker(t *testing.T, fallback bool) (*CircuitBreaker, *MockRepository, *fakeClock) {
+	mr := &MockRepository{}
+	clk := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	b := NewCircuitBreaker(mr, config.CircuitBreaker{FailureThreshold: 3, CoolDown: 10 * time.Second, Fallback: fallback})
+	b.clock = clk
+
+	return b, mr, clk
+}
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b, mr, _ := setupCircuitBreaker(t, false)
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, errConnectionReset)
+
+	for i := 0; i < 3; i++ {
+		_, err := b.FindByID(context.Background(), 1, false)
+		assert.Equal(t, errConnectionReset, err)
+	}
+
+	assert.Equal(t, BreakerOpen, b.State())
+
+	_, err := b.FindByID(context.Background(), 1, false)
+	var open *CircuitOpenError
+	require.True(t, errors.As(err, &open))
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	assert.Equal(t, 10*time.Second, open.RetryAfter)
+	mr.AssertNumberOfCalls(t, "FindByID", 3)
+}
+
+func TestCircuitBreakerIgnoresRequestErrors(t *testing.T) {
+	b, mr, _ := setupCircuitBreaker(t, false)
+	mr.On("FindByID", mock.Anything, 2, mock.Anything).Return(nil, ErrNotFound)
+
+	for i := 0; i < 5; i++ {
+		b.FindByID(context.Background(), 2, false)
+	}
+
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestCircuitBreakerClosesWhenHalfOpenProbeSucceeds(t *testing.T) {
+	b, mr, clk := setupCircuitBreaker(t, false)
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, errConnectionReset).Times(3)
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(&entities.Coffee{ID: 1}, nil)
+
+	for i := 0; i < 3; i++ {
+		b.FindByID(context.Background(), 1, false)
+	}
+	clk.After(10 * time.Second)
+
+	coffee, err := b.FindByID(context.Background(), 1, false)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, coffee.ID)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestCircuitBreakerStaysHalfOpenWhenProbeIsCancelled(t *testing.T) {
+	b, mr, clk := setupCircuitBreaker(t, false)
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, errConnectionReset).Times(3)
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, context.Canceled).Once()
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(&entities.Coffee{ID: 1}, nil)
+
+	for i := 0; i < 3; i++ {
+		b.FindByID(context.Background(), 1, false)
+	}
+	clk.After(10 * time.Second)
+
+	_, err := b.FindByID(context.Background(), 1, false)
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+
+	// The next call probes the database in place of the cancelled one
+	coffee, err := b.FindByID(context.Background(), 1, false)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, coffee.ID)
+	assert.Equal(t, BreakerClosed, b.State())
+	mr.AssertNumberOfCalls(t, "FindByID", 5)
+}
+
+func TestCircuitBreakerIgnoresQueriesCancelledByTheDriver(t *testing.T) {
+	b, mr, _ := setupCircuitBreaker(t, false)
+	// lib/pq reports a cancelled query with the query_canceled code of Postgres
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, &pq.Error{Code: "57014", Message: "canceling statement due to user request"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < 5; i++ {
+		b.FindByID(ctx, 1, false)
+	}
+
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestCircuitBreakerReopensWhenHalfOpenProbeFails(t *testing.T) {
+	b, mr, clk := setupCircuitBreaker(t, false)
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, errConnectionReset)
+
+	for i := 0; i < 3; i++ {
+		b.FindByID(context.Background(), 1, false)
+	}
+	clk.After(10 * time.Second)
+
+	_, err := b.FindByID(context.Background(), 1, false)
+
+	assert.Equal(t, errConnectionReset, err)
+	assert.Equal(t, BreakerOpen, b.State())
+	mr.AssertNumberOfCalls(t, "FindByID", 4)
+}
+
+func TestCircuitBreakerFallsBackToLastSuccessfulFind(t *testing.T) {
+	b, mr, _ := setupCircuitBreaker(t, true)
+	page := &CoffeePage{Coffees: entities.Coffees{{ID: 1}}, Total: 1}
+	mr.On("Find", mock.Anything, CoffeeQuery{}).Return(page, nil).Once()
+	mr.On("Find", mock.Anything, CoffeeQuery{}).Return(nil, errConnectionReset)
+	mr.On("Find", mock.Anything, CoffeeQuery{Limit: 1}).Return(nil, errConnectionReset)
+
+	_, err := b.Find(context.Background(), CoffeeQuery{})
+	require.NoError(t, err)
+
+	stale, err := b.Find(context.Background(), CoffeeQuery{})
+	require.NoError(t, err)
+	assert.True(t, stale.Stale)
+	assert.Equal(t, page.Coffees, stale.Coffees)
+	assert.False(t, page.Stale, "the remembered page is not modified")
+
+	_, err = b.Find(context.Background(), CoffeeQuery{Limit: 1})
+	assert.Equal(t, errConnectionReset, err, "only the result of the same query is a fallback")
+}
+
+func TestCircuitBreakerFallsBackForEqualOptionalFilters(t *testing.T) {
+	b, mr, _ := setupCircuitBreaker(t, true)
+	page := &CoffeePage{Coffees: entities.Coffees{{ID: 1}}, Total: 1}
+	mr.On("Find", mock.Anything, mock.Anything).Return(page, nil).Once()
+	mr.On("Find", mock.Anything, mock.Anything).Return(nil, errConnectionReset)
+
+	query := func() CoffeeQuery {
+		maxPrice, updatedSince := 200.0, time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+		return CoffeeQuery{MaxPrice: &maxPrice, UpdatedSince: &updatedSince}
+	}
+
+	_, err := b.Find(context.Background(), query())
+	require.NoError(t, err)
+
+	stale, err := b.Find(context.Background(), query())
+	require.NoError(t, err)
+	assert.True(t, stale.Stale)
+}
+
+func TestCircuitBreakerPingFailsFastWhileOpen(t *testing.T) {
+	b, mr, _ := setupCircuitBreaker(t, false)
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, errConnectionReset)
+
+	for i := 0; i < 3; i++ {
+		b.FindByID(context.Background(), 1, false)
+	}
+
+	assert.True(t, errors.Is(b.Ping(context.Background()), ErrUnavailable))
+	mr.AssertNotCalled(t, "Ping", mock.Anything)
+}
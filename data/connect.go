@@ -0,0 +1,314 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// clock abstracts time so that the backoff can be tested without waiting
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the clock of the runtime
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// dialer opens a connection to the database
+type dialer func() (Repository, error)
+
+// backoff computes the delay between the connection attempts. The delay
+// doubles from initial up to max, and a random jitter of up to half the delay
+// spreads the reconnections of the instances of the service.
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+	// random returns a number in [0, 1)
+	random func() float64
+}
+
+func newBackoff(cfg config.DBConnect) backoff {
+	return backoff{cfg.InitialBackoff, cfg.MaxBackoff, rand.Float64}
+}
+
+// delay returns the delay after the failed attempt, numbered from 0
+func (b backoff) delay(attempt int) time.Duration {
+	d := b.initial
+	for i := 0; i < attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+
+	half := d / 2
+	return half + time.Duration(b.random()*float64(d-half))
+}
+
+// connector retries to dial the database with backoff
+type connector struct {
+	dial    dialer
+	backoff backoff
+	clock   clock
+	logger  hclog.Logger
+}
+
+// connect dials until a connection succeeds, the context is done, or maxWait
+// elapses. A maxWait of 0 retries forever.
+func (c *connector) connect(ctx context.Context, maxWait time.Duration) (Repository, error) {
+	start := c.clock.Now()
+
+	for attempt := 0; ; attempt++ {
+		repository, err := c.dial()
+		if err == nil {
+			return repository, nil
+		}
+
+		delay := c.backoff.delay(attempt)
+		if maxWait > 0 && c.clock.Now().Add(delay).Sub(start) > maxWait {
+			return nil, fmt.Errorf("unable to connect to database within %s: %w", maxWait, err)
+		}
+
+		c.logger.Error("Unable to connect to database", "error", err, "attempt", attempt+1, "retry_in", delay)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.clock.After(delay):
+		}
+	}
+}
+
+// ReconnectingRepository serves the service in degraded mode: every call fails
+// with ErrUnavailable, and the readiness check with it, until a background
+// goroutine connects to the database.
+type ReconnectingRepository struct {
+	mu         sync.RWMutex
+	repository Repository
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// newReconnectingRepository starts to connect in the background
+func newReconnectingRepository(c *connector) *ReconnectingRepository {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &ReconnectingRepository{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(r.done)
+
+		repository, err := c.connect(ctx, 0)
+		if err != nil {
+			// Only a closed repository stops reconnecting
+			return
+		}
+
+		c.logger.Info("Connected to database, leaving degraded mode")
+		r.mu.Lock()
+		r.repository = repository
+		r.mu.Unlock()
+	}()
+
+	return r
+}
+
+// current returns the connected repository, or ErrUnavailable
+func (r *ReconnectingRepository) current() (Repository, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.repository == nil {
+		return nil, ErrUnavailable
+	}
+
+	return r.repository, nil
+}
+
+// Find implements Repository
+func (r *ReconnectingRepository) Find(ctx context.Context, query CoffeeQuery) (*CoffeePage, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.Find(ctx, query)
+}
+
+// FindByID implements Repository
+func (r *ReconnectingRepository) FindByID(ctx context.Context, id int, includeDeleted bool) (*entities.Coffee, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.FindByID(ctx, id, includeDeleted)
+}
+
+// FindIngredients implements Repository
+func (r *ReconnectingRepository) FindIngredients(ctx context.Context, coffeeID int) (entities.Ingredients, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.FindIngredients(ctx, coffeeID)
+}
+
+// CreateCoffee implements Repository
+func (r *ReconnectingRepository) CreateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.CreateCoffee(ctx, coffee)
+}
+
+// UpdateCoffee implements Repository
+func (r *ReconnectingRepository) UpdateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.UpdateCoffee(ctx, coffee)
+}
+
+// DeleteCoffee implements Repository
+func (r *ReconnectingRepository) DeleteCoffee(ctx context.Context, id int) error {
+	repository, err := r.current()
+	if err != nil {
+		return err
+	}
+
+	return repository.DeleteCoffee(ctx, id)
+}
+
+// RestoreCoffee implements Repository
+func (r *ReconnectingRepository) RestoreCoffee(ctx context.Context, id int) (*entities.Coffee, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.RestoreCoffee(ctx, id)
+}
+
+// Purge implements Repository
+func (r *ReconnectingRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	repository, err := r.current()
+	if err != nil {
+		return 0, err
+	}
+
+	return repository.Purge(ctx, before)
+}
+
+// Revision implements Repository
+func (r *ReconnectingRepository) Revision(ctx context.Context) (time.Time, error) {
+	repository, err := r.current()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return repository.Revision(ctx)
+}
+
+// ListIngredients implements Repository
+func (r *ReconnectingRepository) ListIngredients(ctx context.Context, query IngredientQuery) (entities.Ingredients, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.ListIngredients(ctx, query)
+}
+
+// FindIngredientByID implements Repository
+func (r *ReconnectingRepository) FindIngredientByID(ctx context.Context, id int, includeDeleted bool) (*entities.Ingredient, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.FindIngredientByID(ctx, id, includeDeleted)
+}
+
+// CreateIngredient implements Repository
+func (r *ReconnectingRepository) CreateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.CreateIngredient(ctx, ingredient)
+}
+
+// UpdateIngredient implements Repository
+func (r *ReconnectingRepository) UpdateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error) {
+	repository, err := r.current()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.UpdateIngredient(ctx, ingredient)
+}
+
+// DeleteIngredient implements Repository
+func (r *ReconnectingRepository) DeleteIngredient(ctx context.Context, id int) error {
+	repository, err := r.current()
+	if err != nil {
+		return err
+	}
+
+	return repository.DeleteIngredient(ctx, id)
+}
+
+// Ping fails with ErrUnavailable until connected, failing the readiness check
+func (r *ReconnectingRepository) Ping(ctx context.Context) error {
+	repository, err := r.current()
+	if err != nil {
+		return err
+	}
+
+	return repository.Ping(ctx)
+}
+
+// Stats returns the statistics of the connection pool, empty until connected
+func (r *ReconnectingRepository) Stats() sql.DBStats {
+	repository, err := r.current()
+	if err != nil {
+		return sql.DBStats{}
+	}
+
+	if reporter, ok := repository.(StatsReporter); ok {
+		return reporter.Stats()
+	}
+
+	return sql.DBStats{}
+}
+
+// Close stops reconnecting and closes the connected repository
+func (r *ReconnectingRepository) Close() error {
+	r.cancel()
+	<-r.done
+
+	repository, err := r.current()
+	if err != nil {
+		return nil
+	}
+
+	return repository.Close()
+}
@@ -0,0 +1,140 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock advances instantly by the delays waited for, recording them
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.delays = append(c.delays, d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// fakeDialer fails the first failures attempts, then returns the repository
+type fakeDialer struct {
+	mu         sync.Mutex
+	failures   int
+	attempts   int
+	repository Repository
+}
+
+func (d *fakeDialer) dial() (Repository, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.attempts++
+	if d.attempts <= d.failures {
+		return nil, errors.New("connection refused")
+	}
+
+	return d.repository, nil
+}
+
+func setupConnector(failures int) (*connector, *fakeDialer, *fakeClock) {
+	d := &fakeDialer{failures: failures, repository: &MockRepository{}}
+	c := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	return &connector{
+		dial: d.dial,
+		// The jitter is the upper bound, the delays are the full backoff
+		backoff: backoff{initial: time.Second, max: 8 * time.Second, random: func() float64 { return 0.999999999 }},
+		clock:   c,
+		logger:  hclog.NewNullLogger(),
+	}, d, c
+}
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+	b := backoff{initial: time.Second, max: 8 * time.Second, random: func() float64 { return 0 }}
+
+	assert.Equal(t, 500*time.Millisecond, b.delay(0), "the jitter removes up to half the delay")
+	assert.Equal(t, time.Second, b.delay(1))
+	assert.Equal(t, 2*time.Second, b.delay(2))
+	assert.Equal(t, 4*time.Second, b.delay(3))
+	assert.Equal(t, 4*time.Second, b.delay(10))
+}
+
+func TestConnectRetriesWithBackoff(t *testing.T) {
+	c, d, clk := setupConnector(5)
+
+	repository, err := c.connect(context.Background(), time.Minute)
+
+	require.NoError(t, err)
+	assert.Equal(t, d.repository, repository)
+	assert.Equal(t, 6, d.attempts)
+	require.Len(t, clk.delays, 5)
+	for n, expected := range []time.Duration{1, 2, 4, 8, 8} {
+		assert.InDelta(t, float64(expected*time.Second), float64(clk.delays[n]), float64(time.Millisecond))
+	}
+}
+
+func TestConnectGivesUpAfterMaxWait(t *testing.T) {
+	c, d, clk := setupConnector(100)
+
+	_, err := c.connect(context.Background(), 30*time.Second)
+
+	assert.Error(t, err)
+	assert.Equal(t, 6, d.attempts, "1+2+4+8+8 seconds elapse before the next delay exceeds 30 seconds")
+	assert.True(t, clk.Now().Sub(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)) <= 30*time.Second)
+}
+
+func TestReconnectingRepositoryIsUnavailableUntilConnected(t *testing.T) {
+	c, d, _ := setupConnector(3)
+	mr := d.repository.(*MockRepository)
+	mr.On("Ping", mock.Anything).Return(nil)
+	mr.On("Close").Return(nil)
+
+	// Block the dialer to observe the degraded mode
+	d.mu.Lock()
+	r := newReconnectingRepository(c)
+
+	assert.Equal(t, ErrUnavailable, r.Ping(context.Background()))
+	_, err := r.FindByID(context.Background(), 1, false)
+	assert.Equal(t, ErrUnavailable, err)
+
+	d.mu.Unlock()
+	assert.Eventually(t, func() bool { return r.Ping(context.Background()) == nil }, time.Second, time.Millisecond)
+
+	require.NoError(t, r.Close())
+	mr.AssertCalled(t, "Close")
+}
+
+func TestReconnectingRepositoryCloseStopsReconnecting(t *testing.T) {
+	c, _, _ := setupConnector(1 << 30)
+	// Wait for real so that the reconnection loop is still running
+	c.clock = realClock{}
+	c.backoff = backoff{initial: time.Millisecond, max: time.Millisecond, random: func() float64 { return 0 }}
+
+	r := newReconnectingRepository(c)
+
+	assert.NoError(t, r.Close())
+	assert.Equal(t, ErrUnavailable, r.Ping(context.Background()))
+}
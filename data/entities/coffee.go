@@ -3,9 +3,23 @@ package entities
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
+// ValidationError describes a field of an entity that failed validation.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
+
 // Coffees is a list of Coffee
 type Coffees []Coffee
 
@@ -28,27 +42,65 @@ type Coffee struct {
 	Description string              `db:"description" json:"description"`
 	Price       float64             `db:"price" json:"price"`
 	Image       string              `db:"image" json:"image"`
-	CreatedAt   string              `db:"created_at" json:"-"`
-	UpdatedAt   string              `db:"updated_at" json:"-"`
-	DeletedAt   sql.NullString      `db:"deleted_at" json:"-"`
+	CreatedAt   time.Time           `db:"created_at" json:"-"`
+	UpdatedAt   time.Time           `db:"updated_at" json:"-"`
+	DeletedAt   sql.NullTime        `db:"deleted_at" json:"-"`
 	Ingredients []CoffeeIngredients `json:"ingredients"`
 }
 
+// FromJSON serializes data from json
 func (c *Coffee) FromJSON(data io.Reader) error {
 	de := json.NewDecoder(data)
 	return de.Decode(c)
 }
 
-// ToJSON converts the collection to json
+// ToJSON converts the coffee to json
 func (c *Coffee) ToJSON() ([]byte, error) {
 	return json.Marshal(c)
 }
 
+// Validate checks that the coffee can be written to the database. It returns
+// a *ValidationError describing the first invalid field.
+func (c *Coffee) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return &ValidationError{"name", "is required"}
+	}
+
+	if c.Price < 0 {
+		return &ValidationError{"price", "must not be negative"}
+	}
+
+	seen := map[int]bool{}
+	for _, ci := range c.Ingredients {
+		if ci.IngredientID <= 0 {
+			return &ValidationError{"ingredients", "must reference an ingredient_id"}
+		}
+
+		if seen[ci.IngredientID] {
+			return &ValidationError{"ingredients", fmt.Sprintf("contains ingredient_id %d more than once", ci.IngredientID)}
+		}
+		seen[ci.IngredientID] = true
+
+		if ci.Quantity < 0 {
+			return &ValidationError{"ingredients", "quantity must not be negative"}
+		}
+	}
+
+	return nil
+}
+
+// CoffeeIngredients defines the recipe entry that relates an Ingredient to a
+// Coffee. The name and deletion of the ingredient are loaded with the recipe
+// of a coffee, they are not written.
 type CoffeeIngredients struct {
-	ID           int            `db:"id" json:"-"`
-	CoffeeID     int            `db:"coffee_id" json:"-"`
-	IngredientID int            `db:"ingredient_id" json:"ingredient_id"`
-	CreatedAt    string         `db:"created_at" json:"-"`
-	UpdatedAt    string         `db:"updated_at" json:"-"`
-	DeletedAt    sql.NullString `db:"deleted_at" json:"-"`
+	ID                  int          `db:"id" json:"-"`
+	CoffeeID            int          `db:"coffee_id" json:"-"`
+	IngredientID        int          `db:"ingredient_id" json:"ingredient_id"`
+	Quantity            int          `db:"quantity" json:"quantity"`
+	Unit                string       `db:"unit" json:"unit"`
+	CreatedAt           time.Time    `db:"created_at" json:"-"`
+	UpdatedAt           time.Time    `db:"updated_at" json:"-"`
+	DeletedAt           sql.NullTime `db:"deleted_at" json:"-"`
+	IngredientName      string       `db:"ingredient_name" json:"-"`
+	IngredientDeletedAt sql.NullTime `db:"ingredient_deleted_at" json:"-"`
 }
@@ -35,6 +35,50 @@ func TestCoffeesSerializesToJSON(t *testing.T) {
 	assert.Equal(t, float64(120.12), cd[0]["price"])
 }
 
+func TestCoffeeValidatesName(t *testing.T) {
+	c := Coffee{Name: " ", Price: 120}
+
+	err := c.Validate()
+
+	assert.Error(t, err)
+	assert.Equal(t, "name", err.(*ValidationError).Field)
+}
+
+func TestCoffeeValidatesPrice(t *testing.T) {
+	c := Coffee{Name: "test", Price: -1}
+
+	err := c.Validate()
+
+	assert.Error(t, err)
+	assert.Equal(t, "price", err.(*ValidationError).Field)
+}
+
+func TestCoffeeValidatesIngredients(t *testing.T) {
+	c := Coffee{
+		Name:  "test",
+		Price: 120,
+		Ingredients: []CoffeeIngredients{
+			{IngredientID: 1, Quantity: 40, Unit: "ml"},
+			{IngredientID: 1, Quantity: 40, Unit: "ml"},
+		},
+	}
+
+	err := c.Validate()
+
+	assert.Error(t, err)
+	assert.Equal(t, "ingredients", err.(*ValidationError).Field)
+}
+
+func TestCoffeeValidatesValidCoffee(t *testing.T) {
+	c := Coffee{
+		Name:        "test",
+		Price:       120,
+		Ingredients: []CoffeeIngredients{{IngredientID: 1, Quantity: 40, Unit: "ml"}},
+	}
+
+	assert.NoError(t, c.Validate())
+}
+
 var coffeesData = `
 [
 	{
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"io"
+	"strings"
+	"time"
 )
 
 // Ingredients is a collection of Ingredient
@@ -22,11 +24,46 @@ func (c *Ingredients) ToJSON() ([]byte, error) {
 
 // Ingredient defines an ingredient in the database
 type Ingredient struct {
-	ID        int            `db:"id" json:"id"`
-	Name      string         `db:"name" json:"name"`
-	Quantity  int            `db:"quantity" json:"quantity"`
-	Unit      string         `db:"unit" json:"unit"`
-	CreatedAt string         `db:"created_at" json:"-"`
-	UpdatedAt string         `db:"updated_at" json:"-"`
-	DeletedAt sql.NullString `db:"deleted_at" json:"-"`
+	ID        int          `db:"id" json:"id"`
+	Name      string       `db:"name" json:"name"`
+	Quantity  int          `db:"quantity" json:"quantity"`
+	Unit      string       `db:"unit" json:"unit"`
+	CreatedAt time.Time    `db:"created_at" json:"-"`
+	UpdatedAt time.Time    `db:"updated_at" json:"-"`
+	DeletedAt sql.NullTime `db:"deleted_at" json:"-"`
+}
+
+// MarshalJSON converts the ingredient to json, with its timestamps in RFC 3339
+// and UTC. The timestamps are left out when not loaded, like for the
+// ingredients of a recipe, and deleted_at when the ingredient is not deleted.
+func (i Ingredient) MarshalJSON() ([]byte, error) {
+	type ingredient Ingredient
+
+	return json.Marshal(struct {
+		ingredient
+		CreatedAt *time.Time `json:"created_at,omitempty"`
+		UpdatedAt *time.Time `json:"updated_at,omitempty"`
+		DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	}{ingredient(i), Timestamp(i.CreatedAt), Timestamp(i.UpdatedAt), NullTimestamp(i.DeletedAt)})
+}
+
+// FromJSON serializes data from json
+func (i *Ingredient) FromJSON(data io.Reader) error {
+	de := json.NewDecoder(data)
+	return de.Decode(i)
+}
+
+// ToJSON converts the ingredient to json
+func (i *Ingredient) ToJSON() ([]byte, error) {
+	return json.Marshal(i)
+}
+
+// Validate checks that the ingredient can be written to the database. It
+// returns a *ValidationError describing the first invalid field.
+func (i *Ingredient) Validate() error {
+	if strings.TrimSpace(i.Name) == "" {
+		return &ValidationError{"name", "is required"}
+	}
+
+	return nil
 }
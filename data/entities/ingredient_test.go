@@ -2,8 +2,10 @@ package entities
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -38,6 +40,21 @@ func TestIngredientsSerializesToJSON(t *testing.T) {
 	assert.Equal(t, "ml", id[0]["unit"])
 }
 
+func TestIngredientValidatesName(t *testing.T) {
+	i := Ingredient{Name: ""}
+
+	err := i.Validate()
+
+	assert.Error(t, err)
+	assert.Equal(t, "name", err.(*ValidationError).Field)
+}
+
+func TestIngredientValidatesValidIngredient(t *testing.T) {
+	i := Ingredient{Name: "Espresso"}
+
+	assert.NoError(t, i.Validate())
+}
+
 var ingredientsData = `
 [
    {
@@ -60,3 +77,19 @@ var ingredientsData = `
    }
 ]
 `
+
+func TestIngredientSerializesTimestampsInRFC3339(t *testing.T) {
+	created := time.Date(2021, 3, 1, 13, 0, 0, 0, time.FixedZone("CET", 3600))
+	c := Ingredients{
+		Ingredient{ID: 1, Name: "recipe"},
+		Ingredient{ID: 2, Name: "deleted", CreatedAt: created, UpdatedAt: created, DeletedAt: sql.NullTime{Time: created.Add(time.Hour), Valid: true}},
+	}
+
+	d, err := c.ToJSON()
+	assert.NoError(t, err)
+
+	assert.JSONEq(t, `[
+		{"id": 1, "name": "recipe", "quantity": 0, "unit": ""},
+		{"id": 2, "name": "deleted", "quantity": 0, "unit": "", "created_at": "2021-03-01T12:00:00Z", "updated_at": "2021-03-01T12:00:00Z", "deleted_at": "2021-03-01T13:00:00Z"}
+	]`, string(d))
+}
@@ -0,0 +1,27 @@
+package entities
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Timestamp returns the time in UTC for the json representations, or nil
+// when it is not set
+func Timestamp(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	utc := t.UTC()
+	return &utc
+}
+
+// NullTimestamp returns the time in UTC for the json representations, or nil
+// when it is null
+func NullTimestamp(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return Timestamp(t.Time)
+}
@@ -1,7 +1,11 @@
 package data
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-memdb"
@@ -24,8 +28,17 @@ const (
 	Coffee TableNameKey = "coffee"
 	// CoffeeIngredient is the coffee_ingredient table name
 	CoffeeIngredient TableNameKey = "coffee_ingredient"
+	// Sequence is the table of the last id given in each table
+	Sequence TableNameKey = "sequence"
 )
 
+// sequence is the last id given to the rows of a table. Like a Postgres serial
+// it only goes up, the ids of purged rows are not given again.
+type sequence struct {
+	Table string
+	Last  int
+}
+
 // InMemoryRepository implements the coffee-service.data.Repository interface
 // uisng go-membdb instead of postgres.
 type InMemoryRepository struct {
@@ -45,24 +58,29 @@ func NewInMemoryDB(config *config.Config) (Repository, error) {
 		return &InMemoryRepository{}, err
 	}
 
+	seed, err := LoadSeed(config)
+	if err != nil {
+		return &InMemoryRepository{}, err
+	}
+
 	repository := &InMemoryRepository{db, config}
 
 	repository.config.Logger.Debug("Loading Ingredients")
-	err = repository.loadIngredients()
+	err = repository.loadIngredients(seed)
 	if err != nil {
 		repository.config.Logger.Debug(fmt.Sprintf("Failed to load ingredients with err %+v", err))
 		return &InMemoryRepository{}, err
 	}
 
 	repository.config.Logger.Debug("Loading coffees")
-	err = repository.loadCoffees()
+	err = repository.loadCoffees(seed)
 	if err != nil {
 		repository.config.Logger.Debug(fmt.Sprintf("Failed to load coffees with err %+v", err))
 		return &InMemoryRepository{}, err
 	}
 
 	repository.config.Logger.Debug("Loading coffee ingredients")
-	err = repository.loadCoffeeIngredients()
+	err = repository.loadCoffeeIngredients(seed)
 	if err != nil {
 		repository.config.Logger.Debug(fmt.Sprintf("Failed to load coffee ingredients with err %+v", err))
 		return &InMemoryRepository{}, err
@@ -72,41 +90,720 @@ func NewInMemoryDB(config *config.Config) (Repository, error) {
 	return repository, nil
 }
 
-// Find returns all coffees from the database
-// Used to accept ctx opentracing.SpanContext
-func (r *InMemoryRepository) Find() (entities.Coffees, error) {
+// Find returns the page of coffees from the database matching the query
+func (r *InMemoryRepository) Find(ctx context.Context, query CoffeeQuery) (*CoffeePage, error) {
 	txn := r.db.Txn(false)
 	defer txn.Abort()
 
+	var withIngredient map[int]bool
+	var err error
+
+	if query.IngredientID != 0 {
+		withIngredient, err = coffeesWithIngredient(txn, query.IngredientID)
+		if err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.Find failed to load coffee ingredients", err)
+			return nil, err
+		}
+	}
+
 	iter, err := txn.Get(Coffee.String(), "id")
 	if err != nil {
 		r.config.Logger.Error("coffee-service.data.InMemoryRepository.Find failed to load coffees", err)
 		return nil, err
 	}
 
+	nameContains := strings.ToLower(query.NameContains)
 	coffees := make([]entities.Coffee, 0)
 
-	for coffee := iter.Next(); coffee != nil; coffee = iter.Next() {
-		coffees = append(coffees, *coffee.(*entities.Coffee))
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		coffee := row.(*entities.Coffee)
+
+		if coffee.DeletedAt.Valid && !query.IncludeDeleted {
+			continue
+		}
+		if query.UpdatedSince != nil && !coffee.UpdatedAt.After(*query.UpdatedSince) {
+			continue
+		}
+		if query.MaxPrice != nil && coffee.Price > *query.MaxPrice {
+			continue
+		}
+		if withIngredient != nil && !withIngredient[coffee.ID] {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(coffee.Name), nameContains) {
+			continue
+		}
+
+		coffees = append(coffees, *coffee)
 	}
 
-	for _, coffee := range coffees {
-		coffeeIngredients := make([]entities.CoffeeIngredients, 0)
+	keys := query.sortKeys()
+	sortCoffees(coffees, keys)
+
+	page := &CoffeePage{Total: len(coffees)}
+
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, &entities.ValidationError{Field: "cursor", Reason: "is not valid"}
+		}
 
-		innerIter, err := txn.Get(CoffeeIngredient.String(), "id")
+		start := sort.Search(len(coffees), func(n int) bool {
+			return compareCoffees(cursorFor(coffees[n]), after, keys) > 0
+		})
+		coffees = coffees[start:]
+	}
+
+	if query.Limit > 0 && len(coffees) > query.Limit {
+		coffees = coffees[:query.Limit]
+		page.NextCursor = encodeCursor(coffees[len(coffees)-1])
+	}
+
+	for n, coffee := range coffees {
+		coffeeIngredients, err := findCoffeeIngredients(txn, coffee.ID)
 		if err != nil {
 			r.config.Logger.Error("coffee-service.data.InMemoryRepository.Find failed to load ingredients", err)
 			return nil, err
 		}
 
-		for ingredient := innerIter.Next(); ingredient != nil; ingredient = innerIter.Next() {
-			coffeeIngredients = append(coffeeIngredients, *ingredient.(*entities.CoffeeIngredients))
+		coffees[n].Ingredients = coffeeIngredients
+	}
+
+	page.Coffees = coffees
+
+	return page, nil
+}
+
+// FindByID returns a single coffee from the database, or ErrNotFound
+// if no coffee exists with the given id or it is deleted and includeDeleted is
+// false.
+func (r *InMemoryRepository) FindByID(ctx context.Context, id int, includeDeleted bool) (*entities.Coffee, error) {
+	txn := r.db.Txn(false)
+	defer txn.Abort()
+
+	find := findActive
+	if includeDeleted {
+		find = findAny
+	}
+
+	raw, err := find(txn, Coffee, id)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindByID failed to load coffee", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	// Copy the record so callers can not mutate the contents of the database.
+	coffee := *raw.(*entities.Coffee)
+
+	coffeeIngredients, err := findCoffeeIngredients(txn, coffee.ID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindByID failed to load ingredients", err)
+		return nil, err
+	}
+
+	coffee.Ingredients = coffeeIngredients
+
+	return &coffee, nil
+}
+
+// FindIngredients returns the ingredients, with the quantity and unit used by
+// the recipe, for the coffee with the given id. ErrNotFound is returned if no
+// coffee exists with the given id or it is deleted.
+func (r *InMemoryRepository) FindIngredients(ctx context.Context, coffeeID int) (entities.Ingredients, error) {
+	txn := r.db.Txn(false)
+	defer txn.Abort()
+
+	raw, err := findActive(txn, Coffee, coffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindIngredients failed to load coffee", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	coffeeIngredients, err := findCoffeeIngredients(txn, coffeeID)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindIngredients failed to load coffee ingredients", err)
+		return nil, err
+	}
+
+	ingredients := entities.Ingredients{}
+
+	for _, coffeeIngredient := range coffeeIngredients {
+		raw, err := txn.First(Ingredient.String(), "id", coffeeIngredient.IngredientID)
+		if err != nil {
+			r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindIngredients failed to load ingredient", err)
+			return nil, err
+		}
+		if raw == nil {
+			continue
+		}
+
+		ingredient := *raw.(*entities.Ingredient)
+		ingredient.Quantity = coffeeIngredient.Quantity
+		ingredient.Unit = coffeeIngredient.Unit
+
+		ingredients = append(ingredients, ingredient)
+	}
+
+	return ingredients, nil
+}
+
+// CreateCoffee inserts the coffee and its coffee_ingredient rows in a single
+// transaction, and returns the stored coffee.
+func (r *InMemoryRepository) CreateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	id, err := nextID(txn, Coffee)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := currentTime()
+	coffee.ID = id
+	coffee.CreatedAt = timestamp
+	coffee.UpdatedAt = timestamp
+
+	if err = insertCoffee(txn, coffee); err != nil {
+		return nil, err
+	}
+
+	txn.Commit()
+
+	return r.FindByID(ctx, id, false)
+}
+
+// UpdateCoffee replaces the coffee with the matching id, including its
+// coffee_ingredient rows, in a single transaction. ErrNotFound is returned if
+// no coffee exists with the given id or it is deleted.
+func (r *InMemoryRepository) UpdateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := findActive(txn, Coffee, coffee.ID)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	if err = deleteCoffeeIngredients(txn, coffee.ID); err != nil {
+		return nil, err
+	}
+
+	coffee.CreatedAt = raw.(*entities.Coffee).CreatedAt
+	coffee.UpdatedAt = currentTime()
+
+	if err = insertCoffee(txn, coffee); err != nil {
+		return nil, err
+	}
+
+	txn.Commit()
+
+	return r.FindByID(ctx, coffee.ID, false)
+}
+
+// DeleteCoffee soft deletes the coffee, keeping its recipe so that it can be
+// restored until purged. ErrNotFound is returned if no coffee exists with the
+// given id or it is already deleted.
+func (r *InMemoryRepository) DeleteCoffee(ctx context.Context, id int) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := findActive(txn, Coffee, id)
+	if err != nil {
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	coffee := *raw.(*entities.Coffee)
+	coffee.UpdatedAt = currentTime()
+	coffee.DeletedAt = sql.NullTime{Time: coffee.UpdatedAt, Valid: true}
+
+	if err = txn.Insert(Coffee.String(), &coffee); err != nil {
+		return err
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// RestoreCoffee undoes the soft delete of the coffee, and returns it.
+// Restoring a coffee that is not deleted returns it unchanged. ErrConflict is
+// returned if the recipe uses an ingredient deleted since, and ErrNotFound if
+// no coffee exists with the given id, or it has been purged.
+func (r *InMemoryRepository) RestoreCoffee(ctx context.Context, id int) (*entities.Coffee, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := txn.First(Coffee.String(), "id", id)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	if coffee := *raw.(*entities.Coffee); coffee.DeletedAt.Valid {
+		coffeeIngredients, err := findCoffeeIngredients(txn, id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, coffeeIngredient := range coffeeIngredients {
+			ingredient, err := findActive(txn, Ingredient, coffeeIngredient.IngredientID)
+			if err != nil {
+				return nil, err
+			}
+			if ingredient == nil {
+				return nil, ErrConflict
+			}
+		}
+
+		coffee.UpdatedAt = currentTime()
+		coffee.DeletedAt = sql.NullTime{}
+
+		if err = txn.Insert(Coffee.String(), &coffee); err != nil {
+			return nil, err
+		}
+	}
+
+	txn.Commit()
+
+	return r.FindByID(ctx, id, false)
+}
+
+// Purge hard deletes the coffees, with their recipes, and the ingredients
+// deleted before the time, in a single transaction. Deleted ingredients still
+// used by the recipe of a coffee are kept. It returns the number of coffees
+// and ingredients purged.
+func (r *InMemoryRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	cutoff := before.UTC()
+	purged := 0
+
+	coffees, err := deletedBefore(txn, Coffee, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, coffee := range coffees {
+		if err = deleteCoffeeIngredients(txn, coffee.(*entities.Coffee).ID); err != nil {
+			return 0, err
+		}
+		if err = txn.Delete(Coffee.String(), coffee); err != nil {
+			return 0, err
+		}
+		purged++
+	}
+
+	ingredients, err := deletedBefore(txn, Ingredient, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ingredient := range ingredients {
+		reference, err := txn.First(CoffeeIngredient.String(), "ingredient_id", ingredient.(*entities.Ingredient).ID)
+		if err != nil {
+			return 0, err
+		}
+		if reference != nil {
+			continue
+		}
+
+		if err = txn.Delete(Ingredient.String(), ingredient); err != nil {
+			return 0, err
+		}
+		purged++
+	}
+
+	txn.Commit()
+	return purged, nil
+}
+
+// ListIngredients returns the ingredient catalog matching the query
+func (r *InMemoryRepository) ListIngredients(ctx context.Context, query IngredientQuery) (entities.Ingredients, error) {
+	txn := r.db.Txn(false)
+	defer txn.Abort()
+
+	iter, err := txn.Get(Ingredient.String(), "id")
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.ListIngredients failed to load ingredients", err)
+		return nil, err
+	}
+
+	ingredients := entities.Ingredients{}
+
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		ingredient := row.(*entities.Ingredient)
+		if ingredient.DeletedAt.Valid && !query.IncludeDeleted {
+			continue
+		}
+		if query.UpdatedSince != nil && !ingredient.UpdatedAt.After(*query.UpdatedSince) {
+			continue
+		}
+
+		ingredients = append(ingredients, *ingredient)
+	}
+
+	return ingredients, nil
+}
+
+// Revision returns the time of the latest change to the coffees and the
+// ingredients, deleted rows included, or zero when the database is empty.
+func (r *InMemoryRepository) Revision(ctx context.Context) (time.Time, error) {
+	txn := r.db.Txn(false)
+	defer txn.Abort()
+
+	var revision time.Time
+
+	for _, table := range []TableNameKey{Coffee, Ingredient} {
+		iter, err := txn.Get(table.String(), "id")
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		for row := iter.Next(); row != nil; row = iter.Next() {
+			if updated := updatedAt(row); updated.After(revision) {
+				revision = updated
+			}
+		}
+	}
+
+	return revision, nil
+}
+
+// FindIngredientByID returns a single ingredient from the catalog, or
+// ErrNotFound if no ingredient exists with the given id or it is deleted and
+// includeDeleted is false.
+func (r *InMemoryRepository) FindIngredientByID(ctx context.Context, id int, includeDeleted bool) (*entities.Ingredient, error) {
+	txn := r.db.Txn(false)
+	defer txn.Abort()
+
+	find := findActive
+	if includeDeleted {
+		find = findAny
+	}
+
+	raw, err := find(txn, Ingredient, id)
+	if err != nil {
+		r.config.Logger.Error("coffee-service.data.InMemoryRepository.FindIngredientByID failed to load ingredient", err)
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	ingredient := *raw.(*entities.Ingredient)
+
+	return &ingredient, nil
+}
+
+// CreateIngredient adds an ingredient to the catalog
+func (r *InMemoryRepository) CreateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	id, err := nextID(txn, Ingredient)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := currentTime()
+	ingredient.ID = id
+	ingredient.CreatedAt = timestamp
+	ingredient.UpdatedAt = timestamp
+
+	if err = txn.Insert(Ingredient.String(), &ingredient); err != nil {
+		return nil, err
+	}
+
+	txn.Commit()
+
+	return r.FindIngredientByID(ctx, id, false)
+}
+
+// UpdateIngredient replaces the ingredient with the matching id. ErrNotFound
+// is returned if no ingredient exists with the given id or it is deleted.
+func (r *InMemoryRepository) UpdateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error) {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := findActive(txn, Ingredient, ingredient.ID)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	ingredient.CreatedAt = raw.(*entities.Ingredient).CreatedAt
+	ingredient.UpdatedAt = currentTime()
+
+	if err = txn.Insert(Ingredient.String(), &ingredient); err != nil {
+		return nil, err
+	}
+
+	txn.Commit()
+
+	return r.FindIngredientByID(ctx, ingredient.ID, false)
+}
+
+// DeleteIngredient soft deletes an ingredient of the catalog. ErrConflict is
+// returned if a coffee that is not deleted still uses the ingredient, and
+// ErrNotFound if no ingredient exists with the given id or it is already
+// deleted.
+func (r *InMemoryRepository) DeleteIngredient(ctx context.Context, id int) error {
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	raw, err := findActive(txn, Ingredient, id)
+	if err != nil {
+		return err
+	}
+	if raw == nil {
+		return ErrNotFound
+	}
+
+	iter, err := txn.Get(CoffeeIngredient.String(), "ingredient_id", id)
+	if err != nil {
+		return err
+	}
+
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		coffee, err := findActive(txn, Coffee, row.(*entities.CoffeeIngredients).CoffeeID)
+		if err != nil {
+			return err
+		}
+		if coffee != nil {
+			return ErrConflict
+		}
+	}
+
+	ingredient := *raw.(*entities.Ingredient)
+	ingredient.UpdatedAt = currentTime()
+	ingredient.DeletedAt = sql.NullTime{Time: ingredient.UpdatedAt, Valid: true}
+
+	if err = txn.Insert(Ingredient.String(), &ingredient); err != nil {
+		return err
+	}
+
+	txn.Commit()
+	return nil
+}
+
+// insertCoffee writes the coffee and its recipe, checking that each referenced
+// ingredient exists and is not deleted. The recipe is only stored in the
+// coffee_ingredient table.
+func insertCoffee(txn *memdb.Txn, coffee entities.Coffee) error {
+	for _, ci := range coffee.Ingredients {
+		raw, err := findActive(txn, Ingredient, ci.IngredientID)
+		if err != nil {
+			return err
+		}
+		if raw == nil {
+			return &entities.ValidationError{Field: "ingredients", Reason: fmt.Sprintf("references unknown ingredient_id %d", ci.IngredientID)}
+		}
+
+		id, err := nextID(txn, CoffeeIngredient)
+		if err != nil {
+			return err
+		}
+
+		err = txn.Insert(CoffeeIngredient.String(), &entities.CoffeeIngredients{
+			ID:           id,
+			CoffeeID:     coffee.ID,
+			IngredientID: ci.IngredientID,
+			Quantity:     ci.Quantity,
+			Unit:         ci.Unit,
+			CreatedAt:    coffee.UpdatedAt,
+			UpdatedAt:    coffee.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	coffee.Ingredients = nil
+
+	return txn.Insert(Coffee.String(), &coffee)
+}
+
+// findActive returns the coffee or ingredient with the id, or nil when it does
+// not exist or is deleted.
+func findActive(txn *memdb.Txn, table TableNameKey, id int) (interface{}, error) {
+	raw, err := txn.First(table.String(), "id", id)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	if deletedAt(raw).Valid {
+		return nil, nil
+	}
+
+	return raw, nil
+}
+
+// findAny returns the coffee or ingredient with the given id, deleted or not,
+// or nil when it does not exist
+func findAny(txn *memdb.Txn, table TableNameKey, id int) (interface{}, error) {
+	return txn.First(table.String(), "id", id)
+}
+
+// deletedBefore returns the coffees or ingredients deleted before the cutoff
+func deletedBefore(txn *memdb.Txn, table TableNameKey, cutoff time.Time) ([]interface{}, error) {
+	iter, err := txn.Get(table.String(), "id")
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []interface{}{}
+
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		if deleted := deletedAt(row); deleted.Valid && deleted.Time.Before(cutoff) {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// deletedAt returns the deletion time of a coffee or an ingredient
+func deletedAt(row interface{}) sql.NullTime {
+	switch r := row.(type) {
+	case *entities.Coffee:
+		return r.DeletedAt
+	case *entities.Ingredient:
+		return r.DeletedAt
+	}
+
+	return sql.NullTime{}
+}
+
+// updatedAt returns the update time of a coffee or an ingredient
+func updatedAt(row interface{}) time.Time {
+	switch r := row.(type) {
+	case *entities.Coffee:
+		return r.UpdatedAt
+	case *entities.Ingredient:
+		return r.UpdatedAt
+	}
+
+	return time.Time{}
+}
+
+// deleteCoffeeIngredients removes the recipe for a coffee.
+func deleteCoffeeIngredients(txn *memdb.Txn, coffeeID int) error {
+	_, err := txn.DeleteAll(CoffeeIngredient.String(), "coffee_id", coffeeID)
+	return err
+}
+
+// coffeesWithIngredient returns the ids of the coffees using an ingredient
+// using the ingredient_id index.
+func coffeesWithIngredient(txn *memdb.Txn, ingredientID int) (map[int]bool, error) {
+	iter, err := txn.Get(CoffeeIngredient.String(), "ingredient_id", ingredientID)
+	if err != nil {
+		return nil, err
+	}
+
+	coffeeIDs := map[int]bool{}
+
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		coffeeIDs[row.(*entities.CoffeeIngredients).CoffeeID] = true
+	}
+
+	return coffeeIDs, nil
+}
+
+// findCoffeeIngredients returns the recipe for a coffee using the coffee_id
+// index, with the name and deletion of each ingredient.
+func findCoffeeIngredients(txn *memdb.Txn, coffeeID int) ([]entities.CoffeeIngredients, error) {
+	iter, err := txn.Get(CoffeeIngredient.String(), "coffee_id", coffeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	coffeeIngredients := make([]entities.CoffeeIngredients, 0)
+
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		coffeeIngredients = append(coffeeIngredients, *row.(*entities.CoffeeIngredients))
+	}
+
+	for n, ci := range coffeeIngredients {
+		raw, err := findAny(txn, Ingredient, ci.IngredientID)
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			continue
+		}
+
+		coffeeIngredients[n].IngredientName = raw.(*entities.Ingredient).Name
+		coffeeIngredients[n].IngredientDeletedAt = raw.(*entities.Ingredient).DeletedAt
+	}
+
+	return coffeeIngredients, nil
+}
+
+// nextID returns the next id of the sequence of a table and records it. The
+// sequence starts after the seeded rows.
+func nextID(txn *memdb.Txn, table TableNameKey) (int, error) {
+	raw, err := txn.First(Sequence.String(), "id", table.String())
+	if err != nil {
+		return 0, err
+	}
+
+	last := 0
+	if raw != nil {
+		last = raw.(*sequence).Last
+	} else if last, err = maxID(txn, table); err != nil {
+		return 0, err
+	}
+
+	if err := txn.Insert(Sequence.String(), &sequence{Table: table.String(), Last: last + 1}); err != nil {
+		return 0, err
+	}
+
+	return last + 1, nil
+}
+
+// maxID returns the highest id in a table. The id index is not ordered
+// numerically so the whole table is scanned.
+func maxID(txn *memdb.Txn, table TableNameKey) (int, error) {
+	iter, err := txn.Get(table.String(), "id")
+	if err != nil {
+		return 0, err
+	}
+
+	max := 0
+	for row := iter.Next(); row != nil; row = iter.Next() {
+		var id int
+
+		switch r := row.(type) {
+		case *entities.Coffee:
+			id = r.ID
+		case *entities.Ingredient:
+			id = r.ID
+		case *entities.CoffeeIngredients:
+			id = r.ID
 		}
 
-		coffee.Ingredients = coffeeIngredients
+		if id > max {
+			max = id
+		}
 	}
 
-	return coffees, nil
+	return max, nil
 }
 
 func createSchema() *memdb.DBSchema {
@@ -142,24 +839,37 @@ func createSchema() *memdb.DBSchema {
 						Unique:  true,
 						Indexer: &memdb.IntFieldIndex{Field: "ID"},
 					},
+					"coffee_id": {
+						Name:    "coffee_id",
+						Unique:  false,
+						Indexer: &memdb.IntFieldIndex{Field: "CoffeeID"},
+					},
+					"ingredient_id": {
+						Name:    "ingredient_id",
+						Unique:  false,
+						Indexer: &memdb.IntFieldIndex{Field: "IngredientID"},
+					},
+				},
+			},
+			Sequence.String(): {
+				Name: Sequence.String(),
+				Indexes: map[string]*memdb.IndexSchema{
+					"id": {
+						Name:    "id",
+						Unique:  true,
+						Indexer: &memdb.StringFieldIndex{Field: "Table"},
+					},
 				},
 			},
 		},
 	}
 }
 
-func (r *InMemoryRepository) loadIngredients() error {
-	timestamp := time.Now().String()
+func (r *InMemoryRepository) loadIngredients(seed *Seed) error {
+	timestamp := currentTime()
 	txn := r.db.Txn(true)
 
-	// Insert some people
-	ingredients := []*entities.Ingredient{
-		{ID: 1, Name: "Espresso'", CreatedAt: timestamp, UpdatedAt: timestamp},
-		{ID: 2, Name: "Semi Skimmed Milk", CreatedAt: timestamp, UpdatedAt: timestamp},
-		{ID: 3, Name: "Hot Water", CreatedAt: timestamp, UpdatedAt: timestamp},
-		{ID: 4, Name: "Pumpkin Spice", CreatedAt: timestamp, UpdatedAt: timestamp},
-		{ID: 5, Name: "Steamed Milk", CreatedAt: timestamp, UpdatedAt: timestamp},
-	}
+	ingredients := seed.ingredients(timestamp)
 
 	for _, row := range ingredients {
 		if err := txn.Insert(Ingredient.String(), row); err != nil {
@@ -171,72 +881,11 @@ func (r *InMemoryRepository) loadIngredients() error {
 	return nil
 }
 
-func (r *InMemoryRepository) loadCoffees() error {
-	timestamp := time.Now().String()
+func (r *InMemoryRepository) loadCoffees(seed *Seed) error {
+	timestamp := currentTime()
 	txn := r.db.Txn(true)
 
-	coffees := []*entities.Coffee{
-		{
-			ID:          1,
-			Name:        "Packer Spiced Latte",
-			Teaser:      "Packed with goodness to spice up your images",
-			Description: "",
-			Price:       350,
-			Image:       "/packer.png",
-			CreatedAt:   timestamp,
-			UpdatedAt:   timestamp,
-		},
-		{
-			ID:          2,
-			Name:        "Vaulatte",
-			Teaser:      "Nothing gives you a safe and secure feeling like a Vaulatte",
-			Description: "",
-			Price:       200,
-			Image:       "/vault.png",
-			CreatedAt:   timestamp,
-			UpdatedAt:   timestamp,
-		},
-		{
-			ID:          3,
-			Name:        "Nomadicano",
-			Teaser:      "Drink one today and you will want to schedule another",
-			Description: "",
-			Price:       150,
-			Image:       "/nomad.png",
-			CreatedAt:   timestamp,
-			UpdatedAt:   timestamp,
-		},
-		{
-			ID:          4,
-			Name:        "Terraspresso",
-			Teaser:      "Nothing kickstarts your day like a provision of Terraspresso",
-			Description: "",
-			Price:       150,
-			Image:       "/terraform.png",
-			CreatedAt:   timestamp,
-			UpdatedAt:   timestamp,
-		},
-		{
-			ID:          5,
-			Name:        "Vagrante espresso",
-			Teaser:      "Stdin is not a tty",
-			Description: "",
-			Price:       200,
-			Image:       "/vagrant.png",
-			CreatedAt:   timestamp,
-			UpdatedAt:   timestamp,
-		},
-		{
-			ID:          6,
-			Name:        "Connectaccino",
-			Teaser:      "Discover the wonders of our meshy service",
-			Description: "",
-			Price:       250,
-			Image:       "/consul.png",
-			CreatedAt:   timestamp,
-			UpdatedAt:   timestamp,
-		},
-	}
+	coffees := seed.coffees(timestamp)
 
 	for _, c := range coffees {
 		if err := txn.Insert(Coffee.String(), c); err != nil {
@@ -248,89 +897,11 @@ func (r *InMemoryRepository) loadCoffees() error {
 	return nil
 }
 
-func (r *InMemoryRepository) loadCoffeeIngredients() error {
-	timestamp := time.Now().String()
+func (r *InMemoryRepository) loadCoffeeIngredients(seed *Seed) error {
+	timestamp := currentTime()
 	txn := r.db.Txn(true)
 
-	coffeeIngredients := []*entities.CoffeeIngredients{
-		{
-			ID:           1,
-			CoffeeID:     1,
-			IngredientID: 1,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           2,
-			CoffeeID:     1,
-			IngredientID: 2,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           3,
-			CoffeeID:     1,
-			IngredientID: 4,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           4,
-			CoffeeID:     2,
-			IngredientID: 1,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           5,
-			CoffeeID:     2,
-			IngredientID: 2,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           6,
-			CoffeeID:     3,
-			IngredientID: 1,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           7,
-			CoffeeID:     3,
-			IngredientID: 3,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           8,
-			CoffeeID:     4,
-			IngredientID: 1,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           9,
-			CoffeeID:     5,
-			IngredientID: 1,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           10,
-			CoffeeID:     6,
-			IngredientID: 1,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-		{
-			ID:           11,
-			CoffeeID:     6,
-			IngredientID: 5,
-			CreatedAt:    timestamp,
-			UpdatedAt:    timestamp,
-		},
-	}
+	coffeeIngredients := seed.coffeeIngredients(timestamp)
 
 	for _, ci := range coffeeIngredients {
 		if err := txn.Insert(CoffeeIngredient.String(), ci); err != nil {
@@ -341,3 +912,14 @@ func (r *InMemoryRepository) loadCoffeeIngredients() error {
 	txn.Commit()
 	return nil
 }
+
+// Ping always succeeds as the in memory database cannot be unreachable, unless
+// the context is done.
+func (r *InMemoryRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Close has nothing to release, the data is discarded with the repository
+func (r *InMemoryRepository) Close() error {
+	return nil
+}
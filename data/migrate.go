@@ -0,0 +1,305 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// migrationLockKey identifies the advisory lock held while migrating, it is
+// shared by every replica of the service
+const migrationLockKey = 5181997
+
+// schemaMigrations records the applied migrations, and whether they were
+// baselined on a schema the service did not create
+const schemaMigrations = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL,
+	baselined  BOOLEAN NOT NULL DEFAULT FALSE
+)`
+
+// MigrationStatus reports whether a migration is applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Baselined is set when the migration was recorded without running it,
+	// such a migration is never reverted
+	Baselined bool
+}
+
+// appliedMigration is the record of a migration in schema_migrations
+type appliedMigration struct {
+	at        time.Time
+	baselined bool
+}
+
+// Migrator applies and reverts the schema migrations. Every change holds a
+// lock, so that replicas starting together do not migrate concurrently.
+type Migrator struct {
+	db         *sqlx.DB
+	dialect    dialect
+	migrations []Migration
+	logger     hclog.Logger
+}
+
+// NewMigrator connects to the Postgres database of the configuration, retrying
+// with backoff like the repository does. Only Postgres is migrated, SQLite
+// creates its schema when opened and the in memory database has none.
+func NewMigrator(cfg *config.Config) (*Migrator, error) {
+	if cfg.DBBackend != config.Postgres {
+		return nil, fmt.Errorf("schema migrations are only supported by the %s backend, got %s", config.Postgres, cfg.DBBackend)
+	}
+
+	c := &connector{
+		dial: func() (Repository, error) {
+			return dialPostgres(cfg)
+		},
+		backoff: newBackoff(cfg.DBConnect),
+		clock:   realClock{},
+		logger:  cfg.Logger,
+	}
+
+	repository, err := c.connect(context.Background(), cfg.DBConnect.MaxWait)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMigrator(repository.(*PostgresRepository).db, postgresDialect, migrations, cfg.Logger), nil
+}
+
+// newMigrator creates a Migrator for the migrations, given in version order
+func newMigrator(db *sqlx.DB, d dialect, migrations []Migration, logger hclog.Logger) *Migrator {
+	return &Migrator{db: db, dialect: d, migrations: migrations, logger: logger}
+}
+
+// Status returns every migration of the service, in version order, with
+// whether it is applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	statuses := []MigrationStatus{}
+
+	err := m.withLock(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for _, migration := range m.migrations {
+			record, ok := applied[migration.Version]
+			statuses = append(statuses, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: record.at, Baselined: record.baselined})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down reverts the last applied migration. A baselined migration is not
+// reverted, its Down would drop tables the service did not create.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for n := len(m.migrations) - 1; n >= 0; n-- {
+			if record, ok := applied[m.migrations[n].Version]; ok {
+				if record.baselined {
+					return baselinedError(m.migrations[n])
+				}
+
+				return m.migrate(ctx, conn, m.migrations[n], false)
+			}
+		}
+
+		m.logger.Info("No migration to revert")
+		return nil
+	})
+}
+
+// To applies the pending migrations up to version and reverts the applied
+// migrations after it. Version 0 reverts every migration. Nothing is reverted
+// when one of the migrations to revert is baselined.
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if !m.known(version) {
+		return fmt.Errorf("unknown schema version %d", version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for _, migration := range m.migrations {
+			if record, ok := applied[migration.Version]; ok && record.baselined && migration.Version > version {
+				return baselinedError(migration)
+			}
+		}
+
+		for n := len(m.migrations) - 1; n >= 0; n-- {
+			if _, ok := applied[m.migrations[n].Version]; ok && m.migrations[n].Version > version {
+				if err := m.migrate(ctx, conn, m.migrations[n], false); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				baselined, err := m.baseline(ctx, conn, migration, applied)
+				if err != nil {
+					return err
+				}
+				if baselined {
+					continue
+				}
+
+				if err := m.migrate(ctx, conn, migration, true); err != nil {
+					return err
+				}
+				applied[migration.Version] = appliedMigration{at: currentTime()}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Close closes the connection to the database
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// known reports whether the version is 0 or the version of a migration
+func (m *Migrator) known(version int) bool {
+	if version == 0 {
+		return true
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withLock calls f with the applied migrations while holding the lock. A
+// session lock belongs to a connection, so every statement runs on conn.
+func (m *Migrator) withLock(ctx context.Context, f func(conn *sql.Conn, applied map[int]appliedMigration) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect.lock != "" {
+		if _, err := conn.ExecContext(ctx, m.dialect.lock, migrationLockKey); err != nil {
+			return fmt.Errorf("unable to lock schema migrations: %w", err)
+		}
+
+		defer func() {
+			// Unlock even when ctx is done, the connection returns to the pool
+			if _, err := conn.ExecContext(context.Background(), m.dialect.unlock, migrationLockKey); err != nil {
+				m.logger.Error("Unable to unlock schema migrations", "error", err)
+			}
+		}()
+	}
+
+	if _, err := conn.ExecContext(ctx, schemaMigrations); err != nil {
+		return err
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return f(conn, applied)
+}
+
+// applied returns the time each applied migration was applied at, by version
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at, baselined FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+
+	for rows.Next() {
+		var version int
+		var record appliedMigration
+
+		if err := rows.Scan(&version, &record.at, &record.baselined); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+
+	return applied, rows.Err()
+}
+
+// baseline records the migration as applied, without running it, when no
+// migration is applied yet and the database already has its schema. A schema
+// that is missing or incompatible fails the baseline query, and the migration
+// is applied instead.
+func (m *Migrator) baseline(ctx context.Context, conn *sql.Conn, migration Migration, applied map[int]appliedMigration) (bool, error) {
+	if migration.Baseline == "" || len(applied) > 0 {
+		return false, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, migration.Baseline); err != nil {
+		return false, nil
+	}
+
+	record := appliedMigration{at: currentTime(), baselined: true}
+	_, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at, baselined) VALUES ($1, $2, $3, $4)", migration.Version, migration.Name, record.at, record.baselined)
+	if err != nil {
+		return false, fmt.Errorf("unable to baseline migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+	applied[migration.Version] = record
+
+	m.logger.Info("Migrated schema", "action", "baseline", "version", migration.Version, "name", migration.Name)
+	return true, nil
+}
+
+// baselinedError reports a baselined migration that was asked to be reverted
+func baselinedError(migration Migration) error {
+	return fmt.Errorf("migration %d %s was baselined on an existing schema and can not be reverted", migration.Version, migration.Name)
+}
+
+// migrate applies, or reverts, the migration and records it in a single
+// transaction
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	action, statement := "apply", migration.Up
+	record, args := "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", []interface{}{migration.Version, migration.Name, currentTime()}
+	if !up {
+		action, statement = "revert", migration.Down
+		record, args = "DELETE FROM schema_migrations WHERE version=$1", []interface{}{migration.Version}
+	}
+
+	if _, err = tx.ExecContext(ctx, statement); err == nil {
+		if _, err = tx.ExecContext(ctx, record, args...); err == nil {
+			err = tx.Commit()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to %s migration %d %s: %w", action, migration.Version, migration.Name, err)
+	}
+
+	m.logger.Info("Migrated schema", "action", action, "version", migration.Version, "name", migration.Name)
+	return nil
+}
@@ -0,0 +1,203 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_coffee", Up: "CREATE TABLE coffee (id INTEGER PRIMARY KEY)", Down: "DROP TABLE coffee", Baseline: "SELECT id FROM coffee WHERE 1 = 0"},
+	{Version: 2, Name: "create_ingredient", Up: "CREATE TABLE ingredient (id INTEGER PRIMARY KEY)", Down: "DROP TABLE ingredient"},
+	{Version: 3, Name: "add_coffee_name", Up: "ALTER TABLE coffee ADD COLUMN name TEXT", Down: "ALTER TABLE coffee DROP COLUMN name"},
+}
+
+// setupMigrator returns a Migrator for the test migrations on an empty SQLite
+// database
+func setupMigrator(t *testing.T, migrations []Migration) (*Migrator, *sqlx.DB) {
+	db, err := sqlx.Open("sqlite3", sqliteDSN(filepath.Join(t.TempDir(), "migrate.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return newMigrator(db, sqliteDialect, migrations, hclog.NewNullLogger()), db
+}
+
+// appliedVersions returns the versions recorded in schema_migrations
+func appliedVersions(t *testing.T, db *sqlx.DB) []int {
+	versions := []int{}
+	require.NoError(t, db.Select(&versions, "SELECT version FROM schema_migrations ORDER BY version"))
+
+	return versions
+}
+
+func TestMigrationsAreInVersionOrder(t *testing.T) {
+	for n, migration := range migrations {
+		assert.Equal(t, n+1, migration.Version, migration.Name)
+		assert.NotEmpty(t, migration.Name)
+		assert.NotEmpty(t, migration.Up, migration.Name)
+		assert.NotEmpty(t, migration.Down, migration.Name)
+	}
+}
+
+func TestMigratorUpAppliesPendingMigrations(t *testing.T) {
+	migrator, db := setupMigrator(t, testMigrations[:2])
+
+	require.NoError(t, migrator.Up(context.Background()))
+	assert.Equal(t, []int{1, 2}, appliedVersions(t, db))
+
+	// A new release applies only its new migration
+	migrator.migrations = testMigrations
+	require.NoError(t, migrator.Up(context.Background()))
+	require.NoError(t, migrator.Up(context.Background()))
+
+	assert.Equal(t, []int{1, 2, 3}, appliedVersions(t, db))
+	_, err := db.Exec("INSERT INTO coffee (id, name) VALUES (1, 'Vaulatte')")
+	assert.NoError(t, err)
+}
+
+func TestMigratorBaselinesExistingSchema(t *testing.T) {
+	migrator, db := setupMigrator(t, testMigrations)
+	_, err := db.Exec("CREATE TABLE coffee (id INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+
+	require.NoError(t, migrator.Up(context.Background()))
+
+	assert.Equal(t, []int{1, 2, 3}, appliedVersions(t, db))
+	_, err = db.Exec("INSERT INTO coffee (id, name) VALUES (1, 'Vaulatte')")
+	assert.NoError(t, err)
+}
+
+func TestMigratorRefusesToRevertBaselinedMigration(t *testing.T) {
+	migrator, db := setupMigrator(t, testMigrations)
+	_, err := db.Exec("CREATE TABLE coffee (id INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(context.Background()))
+
+	// The migrations applied after the baseline are reverted
+	require.NoError(t, migrator.To(context.Background(), 1))
+	assert.Equal(t, []int{1}, appliedVersions(t, db))
+
+	assert.Error(t, migrator.Down(context.Background()))
+	assert.Error(t, migrator.To(context.Background(), 0))
+
+	assert.Equal(t, []int{1}, appliedVersions(t, db))
+	_, err = db.Exec("INSERT INTO coffee (id) VALUES (1)")
+	assert.NoError(t, err, "the existing table is kept")
+
+	statuses, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Baselined)
+	assert.False(t, statuses[1].Baselined)
+}
+
+func TestMigratorAppliesIncompatibleSchema(t *testing.T) {
+	migrator, db := setupMigrator(t, testMigrations)
+	_, err := db.Exec("CREATE TABLE coffee (coffee_id INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+
+	// Creating the existing table fails rather than recording a schema the
+	// service can not use
+	assert.Error(t, migrator.Up(context.Background()))
+	assert.Empty(t, appliedVersions(t, db))
+}
+
+func TestMigrationBaselineMatchesTheRepositorySchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coffee-service.db")
+	repository, err := NewSQLite(&config.Config{Logger: hclog.NewNullLogger(), SQLitePath: path})
+	require.NoError(t, err)
+	t.Cleanup(func() { repository.Close() })
+
+	_, err = repository.(*SQLiteRepository).db.Exec(migrations[0].Baseline)
+	assert.NoError(t, err)
+
+	_, db := setupMigrator(t, migrations)
+	_, err = db.Exec(migrations[0].Baseline)
+	assert.Error(t, err, "an empty database has no schema to baseline")
+}
+
+func TestMigratorDownRevertsLastMigration(t *testing.T) {
+	migrator, db := setupMigrator(t, testMigrations)
+	require.NoError(t, migrator.Up(context.Background()))
+
+	require.NoError(t, migrator.Down(context.Background()))
+
+	assert.Equal(t, []int{1, 2}, appliedVersions(t, db))
+	_, err := db.Exec("INSERT INTO coffee (id, name) VALUES (1, 'Vaulatte')")
+	assert.Error(t, err)
+}
+
+func TestMigratorToMigratesInBothDirections(t *testing.T) {
+	migrator, db := setupMigrator(t, testMigrations)
+
+	require.NoError(t, migrator.To(context.Background(), 2))
+	assert.Equal(t, []int{1, 2}, appliedVersions(t, db))
+
+	require.NoError(t, migrator.To(context.Background(), 3))
+	assert.Equal(t, []int{1, 2, 3}, appliedVersions(t, db))
+
+	require.NoError(t, migrator.To(context.Background(), 0))
+	assert.Equal(t, []int{}, appliedVersions(t, db))
+
+	assert.Error(t, migrator.To(context.Background(), 4))
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	broken := append(testMigrations[:1:1], Migration{Version: 2, Name: "broken", Up: "CREATE TABLE ingredient (id INTEGER PRIMARY KEY); NOT SQL", Down: ""})
+	migrator, db := setupMigrator(t, broken)
+
+	err := migrator.Up(context.Background())
+	assert.EqualError(t, err, `unable to apply migration 2 broken: near "NOT": syntax error`)
+
+	assert.Equal(t, []int{1}, appliedVersions(t, db))
+	_, err = db.Exec("SELECT id FROM ingredient")
+	assert.Error(t, err)
+}
+
+func TestMigratorStatusReportsAppliedMigrations(t *testing.T) {
+	migrator, _ := setupMigrator(t, testMigrations)
+	require.NoError(t, migrator.To(context.Background(), 1))
+
+	statuses, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, statuses, 3)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[0].AppliedAt.IsZero())
+	assert.False(t, statuses[1].Applied)
+	assert.False(t, statuses[2].Applied)
+}
+
+func TestMigratorHoldsAdvisoryLockOnPostgres(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at, baselined FROM schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at", "baselined"}))
+	mock.ExpectExec(`SELECT id, name, .* FROM ingredient WHERE 1 = 0`).WillReturnError(errors.New(`relation "ingredient" does not exist`))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE ingredient`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(1, "create_menu", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	migrator := newMigrator(sqlx.NewDb(db, "postgres"), postgresDialect, migrations[:1], hclog.NewNullLogger())
+	require.NoError(t, migrator.Up(context.Background()))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewMigratorRequiresPostgres(t *testing.T) {
+	_, err := NewMigrator(&config.Config{DBBackend: config.SQLite, Logger: hclog.NewNullLogger()})
+
+	assert.Error(t, err)
+}
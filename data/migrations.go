@@ -0,0 +1,80 @@
+package data
+
+// Migration is a versioned change of the Postgres schema. Up applies the
+// change and Down reverts it, each in a single transaction. Baseline, when
+// set, succeeds only if the database already has a compatible schema, created
+// before it was migrated, and the migration is then recorded without running
+// Up.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Baseline string
+}
+
+// migrations are the Postgres schema migrations compiled into the service, in
+// version order. Released migrations must never change, the schema is changed
+// by appending a new version.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_menu",
+		Up: `
+CREATE TABLE ingredient (
+	id         SERIAL PRIMARY KEY,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	deleted_at TIMESTAMP
+);
+
+CREATE TABLE coffee (
+	id          SERIAL PRIMARY KEY,
+	name        TEXT NOT NULL,
+	teaser      TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	price       DOUBLE PRECISION NOT NULL,
+	image       TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMP NOT NULL,
+	updated_at  TIMESTAMP NOT NULL,
+	deleted_at  TIMESTAMP
+);
+
+CREATE TABLE coffee_ingredient (
+	id            SERIAL PRIMARY KEY,
+	coffee_id     INTEGER NOT NULL REFERENCES coffee (id),
+	ingredient_id INTEGER NOT NULL REFERENCES ingredient (id),
+	quantity      INTEGER NOT NULL DEFAULT 0,
+	unit          TEXT NOT NULL DEFAULT '',
+	created_at    TIMESTAMP NOT NULL,
+	updated_at    TIMESTAMP NOT NULL,
+	deleted_at    TIMESTAMP
+);
+`,
+		Down: `
+DROP TABLE coffee_ingredient;
+DROP TABLE coffee;
+DROP TABLE ingredient;
+`,
+		// The tables shared with the product-api database have every column
+		// the service reads
+		Baseline: `
+SELECT id, name, created_at, updated_at, deleted_at FROM ingredient WHERE 1 = 0;
+SELECT id, name, teaser, description, price, image, created_at, updated_at, deleted_at FROM coffee WHERE 1 = 0;
+SELECT id, coffee_id, ingredient_id, quantity, unit, created_at, updated_at, deleted_at FROM coffee_ingredient WHERE 1 = 0;
+`,
+	},
+	{
+		Version: 2,
+		Name:    "index_coffee_ingredient",
+		Up: `
+CREATE INDEX coffee_ingredient_coffee_id ON coffee_ingredient (coffee_id);
+CREATE INDEX coffee_ingredient_ingredient_id ON coffee_ingredient (ingredient_id);
+`,
+		Down: `
+DROP INDEX coffee_ingredient_ingredient_id;
+DROP INDEX coffee_ingredient_coffee_id;
+`,
+	},
+}
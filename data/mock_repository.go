@@ -1,6 +1,9 @@
 package data
 
 import (
+	"context"
+	"time"
+
 	"github.com/stretchr/testify/mock"
 
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
@@ -12,12 +15,157 @@ type MockRepository struct {
 }
 
 // Find mock stub
-func (r *MockRepository) Find() (entities.Coffees, error) {
-	args := r.Called()
+func (r *MockRepository) Find(ctx context.Context, query CoffeeQuery) (*CoffeePage, error) {
+	args := r.Called(ctx, query)
+
+	if m, ok := args.Get(0).(*CoffeePage); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// FindByID mock stub
+func (r *MockRepository) FindByID(ctx context.Context, id int, includeDeleted bool) (*entities.Coffee, error) {
+	args := r.Called(ctx, id, includeDeleted)
+
+	if m, ok := args.Get(0).(*entities.Coffee); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// FindIngredients mock stub
+func (r *MockRepository) FindIngredients(ctx context.Context, coffeeID int) (entities.Ingredients, error) {
+	args := r.Called(ctx, coffeeID)
+
+	if m, ok := args.Get(0).(entities.Ingredients); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
 
-	if m, ok := args.Get(0).(entities.Coffees); ok {
+// CreateCoffee mock stub
+func (r *MockRepository) CreateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error) {
+	args := r.Called(ctx, coffee)
+
+	if m, ok := args.Get(0).(*entities.Coffee); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// UpdateCoffee mock stub
+func (r *MockRepository) UpdateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error) {
+	args := r.Called(ctx, coffee)
+
+	if m, ok := args.Get(0).(*entities.Coffee); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// DeleteCoffee mock stub
+func (r *MockRepository) DeleteCoffee(ctx context.Context, id int) error {
+	args := r.Called(ctx, id)
+
+	return args.Error(0)
+}
+
+// RestoreCoffee mock stub
+func (r *MockRepository) RestoreCoffee(ctx context.Context, id int) (*entities.Coffee, error) {
+	args := r.Called(ctx, id)
+
+	if m, ok := args.Get(0).(*entities.Coffee); ok {
 		return m, args.Error(1)
 	}
 
 	return nil, args.Error(1)
 }
+
+// Purge mock stub
+func (r *MockRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	args := r.Called(ctx, before)
+
+	return args.Int(0), args.Error(1)
+}
+
+// Revision mock stub
+func (r *MockRepository) Revision(ctx context.Context) (time.Time, error) {
+	args := r.Called(ctx)
+
+	if m, ok := args.Get(0).(time.Time); ok {
+		return m, args.Error(1)
+	}
+
+	return time.Time{}, args.Error(1)
+}
+
+// ListIngredients mock stub
+func (r *MockRepository) ListIngredients(ctx context.Context, query IngredientQuery) (entities.Ingredients, error) {
+	args := r.Called(ctx, query)
+
+	if m, ok := args.Get(0).(entities.Ingredients); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// FindIngredientByID mock stub
+func (r *MockRepository) FindIngredientByID(ctx context.Context, id int, includeDeleted bool) (*entities.Ingredient, error) {
+	args := r.Called(ctx, id, includeDeleted)
+
+	if m, ok := args.Get(0).(*entities.Ingredient); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// CreateIngredient mock stub
+func (r *MockRepository) CreateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error) {
+	args := r.Called(ctx, ingredient)
+
+	if m, ok := args.Get(0).(*entities.Ingredient); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// UpdateIngredient mock stub
+func (r *MockRepository) UpdateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error) {
+	args := r.Called(ctx, ingredient)
+
+	if m, ok := args.Get(0).(*entities.Ingredient); ok {
+		return m, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+// DeleteIngredient mock stub
+func (r *MockRepository) DeleteIngredient(ctx context.Context, id int) error {
+	args := r.Called(ctx, id)
+
+	return args.Error(0)
+}
+
+// Ping mock stub
+func (r *MockRepository) Ping(ctx context.Context) error {
+	args := r.Called(ctx)
+
+	return args.Error(0)
+}
+
+// Close mock stub
+func (r *MockRepository) Close() error {
+	args := r.Called()
+
+	return args.Error(0)
+}
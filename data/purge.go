@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// Purger hard deletes the coffees and ingredients soft deleted for longer
+// than the retention. Purging is idempotent, so every replica of the service
+// can run it.
+type Purger struct {
+	repository Repository
+	retention  time.Duration
+	interval   time.Duration
+	clock      clock
+	logger     hclog.Logger
+}
+
+// NewPurger creates a Purger for the repository
+func NewPurger(repository Repository, cfg config.Purge, logger hclog.Logger) *Purger {
+	return &Purger{
+		repository: repository,
+		retention:  cfg.Retention,
+		interval:   cfg.Interval,
+		clock:      realClock{},
+		logger:     logger,
+	}
+}
+
+// Run purges the repository on start and then every interval, until ctx is
+// done. Failures are logged and retried on the next run.
+func (p *Purger) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if _, err := p.Purge(ctx); err != nil && ctx.Err() == nil {
+			p.logger.Error("Unable to purge deleted rows", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(p.interval):
+		}
+	}
+}
+
+// Purge hard deletes the rows deleted before the retention, and returns the
+// number of rows purged
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	before := p.clock.Now().Add(-p.retention)
+
+	purged, err := p.repository.Purge(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	if purged > 0 {
+		p.logger.Info("Purged deleted rows", "rows", purged, "before", before.UTC().Format(time.RFC3339))
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+func setupPurger(mr *MockRepository) (*Purger, *fakeClock) {
+	clk := &fakeClock{now: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+	p := NewPurger(mr, config.Purge{Retention: 24 * time.Hour, Interval: time.Hour}, hclog.NewNullLogger())
+	p.clock = clk
+
+	return p, clk
+}
+
+func TestPurgerPurgesRowsDeletedBeforeRetention(t *testing.T) {
+	mr := &MockRepository{}
+	p, _ := setupPurger(mr)
+	mr.On("Purge", mock.Anything, time.Date(2021, 2, 28, 12, 0, 0, 0, time.UTC)).Return(3, nil)
+
+	purged, err := p.Purge(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+}
+
+func TestPurgerRunsEveryIntervalUntilCancelled(t *testing.T) {
+	mr := &MockRepository{}
+	p, clk := setupPurger(mr)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Failures are retried on the next run
+	mr.On("Purge", mock.Anything, mock.Anything).Return(0, errors.New("connection refused")).Once()
+	mr.On("Purge", mock.Anything, mock.Anything).Return(0, nil).Once()
+	mr.On("Purge", mock.Anything, mock.Anything).Return(0, nil).Once().Run(func(mock.Arguments) { cancel() })
+
+	p.Run(ctx)
+
+	mr.AssertNumberOfCalls(t, "Purge", 3)
+	for _, d := range clk.delays {
+		assert.Equal(t, time.Hour, d)
+	}
+}
@@ -0,0 +1,307 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// MaxLimit is the largest page size a CoffeeQuery may request.
+const MaxLimit = 100
+
+// SortField is a typesafe discriminator for the fields coffees can be sorted by.
+type SortField string
+
+func (s SortField) String() string {
+	return string(s)
+}
+
+const (
+	// SortByID sorts coffees by id
+	SortByID SortField = "id"
+	// SortByName sorts coffees by name
+	SortByName SortField = "name"
+	// SortByPrice sorts coffees by price
+	SortByPrice SortField = "price"
+)
+
+// Sort is a single sort key of a CoffeeQuery.
+type Sort struct {
+	Field      SortField
+	Descending bool
+}
+
+// CoffeeQuery describes the filtering, sorting and pagination applied by
+// Repository.Find. The zero value returns every coffee ordered by id.
+type CoffeeQuery struct {
+	// Limit is the maximum number of coffees to return, 0 returns every coffee.
+	Limit int
+	// Cursor is the opaque position returned as CoffeePage.NextCursor.
+	Cursor string
+	// Sort is the sort order, id is always used as the final key.
+	Sort []Sort
+	// MaxPrice excludes coffees more expensive than the price when set.
+	MaxPrice *float64
+	// IngredientID only includes coffees using the ingredient when not 0.
+	IngredientID int
+	// NameContains only includes coffees whose name contains the string,
+	// ignoring case, when not empty.
+	NameContains string
+	// IncludeDeleted includes the soft deleted coffees.
+	IncludeDeleted bool
+	// UpdatedSince only includes coffees updated after the time when set.
+	UpdatedSince *time.Time
+}
+
+// IngredientQuery describes the filtering applied by
+// Repository.ListIngredients. The zero value returns every ingredient that
+// is not deleted.
+type IngredientQuery struct {
+	// IncludeDeleted includes the soft deleted ingredients.
+	IncludeDeleted bool
+	// UpdatedSince only includes ingredients updated after the time when set.
+	UpdatedSince *time.Time
+}
+
+// CoffeePage is the result of Repository.Find.
+type CoffeePage struct {
+	Coffees entities.Coffees
+	// Total is the number of coffees matching the filters of the query,
+	// ignoring pagination.
+	Total int
+	// NextCursor is the cursor of the following page, or empty on the last page.
+	NextCursor string
+	// Stale is set when the page is a previous result served while the
+	// database fails.
+	Stale bool
+}
+
+// cursor is the decoded form of CoffeeQuery.Cursor. It holds the values of
+// every sortable field of the last coffee on the previous page.
+type cursor struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// ParseCoffeeQuery reads a CoffeeQuery from the query string parameters
+// limit, cursor, sort, max_price, ingredient, name_contains, include_deleted
+// and updated_since. Invalid parameters are reported with a
+// *entities.ValidationError.
+func ParseCoffeeQuery(values url.Values) (CoffeeQuery, error) {
+	query := CoffeeQuery{
+		Cursor:       values.Get("cursor"),
+		NameContains: values.Get("name_contains"),
+	}
+
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return query, &entities.ValidationError{Field: "limit", Reason: fmt.Sprintf("must be between 1 and %d", MaxLimit)}
+		}
+		query.Limit = limit
+	}
+
+	if query.Cursor != "" {
+		if _, err := decodeCursor(query.Cursor); err != nil {
+			return query, &entities.ValidationError{Field: "cursor", Reason: "is not valid"}
+		}
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		for _, key := range strings.Split(raw, ",") {
+			s := Sort{}
+			if strings.HasPrefix(key, "-") {
+				s.Descending = true
+				key = key[1:]
+			}
+
+			switch SortField(key) {
+			case SortByID, SortByName, SortByPrice:
+				s.Field = SortField(key)
+			default:
+				return query, &entities.ValidationError{Field: "sort", Reason: fmt.Sprintf("can not sort by %q", key)}
+			}
+
+			query.Sort = append(query.Sort, s)
+		}
+	}
+
+	if raw := values.Get("max_price"); raw != "" {
+		maxPrice, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return query, &entities.ValidationError{Field: "max_price", Reason: "must be a number"}
+		}
+		query.MaxPrice = &maxPrice
+	}
+
+	if raw := values.Get("ingredient"); raw != "" {
+		ingredientID, err := strconv.Atoi(raw)
+		if err != nil || ingredientID < 1 {
+			return query, &entities.ValidationError{Field: "ingredient", Reason: "must be an ingredient id"}
+		}
+		query.IngredientID = ingredientID
+	}
+
+	includeDeleted, err := ParseIncludeDeleted(values)
+	if err != nil {
+		return query, err
+	}
+	query.IncludeDeleted = includeDeleted
+
+	if query.UpdatedSince, err = parseUpdatedSince(values); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+// ParseIngredientQuery reads an IngredientQuery from the include_deleted and
+// updated_since query string parameters. Invalid parameters are reported with
+// a *entities.ValidationError.
+func ParseIngredientQuery(values url.Values) (IngredientQuery, error) {
+	query := IngredientQuery{}
+
+	includeDeleted, err := ParseIncludeDeleted(values)
+	if err != nil {
+		return query, err
+	}
+	query.IncludeDeleted = includeDeleted
+
+	if query.UpdatedSince, err = parseUpdatedSince(values); err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
+
+// ParseIncludeDeleted reads the include_deleted query string parameter, which
+// also applies to the reads of a single coffee. An invalid value is reported
+// with a *entities.ValidationError.
+func ParseIncludeDeleted(values url.Values) (bool, error) {
+	raw := values.Get("include_deleted")
+	if raw == "" {
+		return false, nil
+	}
+
+	includeDeleted, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, &entities.ValidationError{Field: "include_deleted", Reason: "must be true or false"}
+	}
+
+	return includeDeleted, nil
+}
+
+// parseUpdatedSince reads the RFC 3339 time of the updated_since parameter, in
+// UTC
+func parseUpdatedSince(values url.Values) (*time.Time, error) {
+	raw := values.Get("updated_since")
+	if raw == "" {
+		return nil, nil
+	}
+
+	updatedSince, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, &entities.ValidationError{Field: "updated_since", Reason: "must be an RFC 3339 time, e.g. 2021-03-01T12:00:00Z"}
+	}
+	updatedSince = updatedSince.UTC()
+
+	return &updatedSince, nil
+}
+
+// key identifies the query by the values of its fields, rather than the
+// addresses of the optional ones
+func (q CoffeeQuery) key() string {
+	maxPrice, updatedSince := "", ""
+	if q.MaxPrice != nil {
+		maxPrice = strconv.FormatFloat(*q.MaxPrice, 'g', -1, 64)
+	}
+	if q.UpdatedSince != nil {
+		updatedSince = q.UpdatedSince.Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprintf("%d|%s|%v|%s|%d|%s|%t|%s", q.Limit, q.Cursor, q.Sort, maxPrice, q.IngredientID, q.NameContains, q.IncludeDeleted, updatedSince)
+}
+
+// sortKeys returns the sort order of the query with id appended as the final
+// key, so that every coffee has a unique position.
+func (q CoffeeQuery) sortKeys() []Sort {
+	keys := make([]Sort, 0, len(q.Sort)+1)
+
+	for _, s := range q.Sort {
+		keys = append(keys, s)
+		if s.Field == SortByID {
+			return keys
+		}
+	}
+
+	return append(keys, Sort{Field: SortByID})
+}
+
+// compareCoffees orders two coffees by the sort keys, returning a negative
+// number when a sorts before b, a positive number when a sorts after b and 0
+// when they share a position.
+func compareCoffees(a, b cursor, keys []Sort) int {
+	for _, key := range keys {
+		result := 0
+
+		switch key.Field {
+		case SortByID:
+			result = a.ID - b.ID
+		case SortByName:
+			result = strings.Compare(a.Name, b.Name)
+		case SortByPrice:
+			if a.Price < b.Price {
+				result = -1
+			} else if a.Price > b.Price {
+				result = 1
+			}
+		}
+
+		if key.Descending {
+			result = -result
+		}
+
+		if result != 0 {
+			return result
+		}
+	}
+
+	return 0
+}
+
+// sortCoffees sorts the coffees in place by the sort keys.
+func sortCoffees(coffees entities.Coffees, keys []Sort) {
+	sort.SliceStable(coffees, func(i, j int) bool {
+		return compareCoffees(cursorFor(coffees[i]), cursorFor(coffees[j]), keys) < 0
+	})
+}
+
+func cursorFor(coffee entities.Coffee) cursor {
+	return cursor{ID: coffee.ID, Name: coffee.Name, Price: coffee.Price}
+}
+
+func encodeCursor(coffee entities.Coffee) string {
+	// Marshalling a struct of basic types can not fail.
+	raw, _ := json.Marshal(cursorFor(coffee))
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	c := cursor{}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(raw, &c)
+	return c, err
+}
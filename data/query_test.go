@@ -0,0 +1,78 @@
+package data
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestParseCoffeeQueryReadsParameters(t *testing.T) {
+	values, _ := url.ParseQuery("limit=10&sort=price,-name&max_price=2.5&ingredient=2&name_contains=latte&include_deleted=true&updated_since=2021-03-01T13:00:00%2B01:00")
+
+	query, err := ParseCoffeeQuery(values)
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, query.Limit)
+	assert.Equal(t, []Sort{{Field: SortByPrice}, {Field: SortByName, Descending: true}}, query.Sort)
+	assert.Equal(t, 2.5, *query.MaxPrice)
+	assert.Equal(t, 2, query.IngredientID)
+	assert.Equal(t, "latte", query.NameContains)
+	assert.True(t, query.IncludeDeleted)
+	assert.Equal(t, time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), *query.UpdatedSince)
+}
+
+func TestParseCoffeeQueryRejectsInvalidParameters(t *testing.T) {
+	parameters := map[string]string{
+		"limit":           "limit=1000",
+		"cursor":          "cursor=not-a-cursor",
+		"sort":            "sort=teaser",
+		"max_price":       "max_price=cheap",
+		"ingredient":      "ingredient=0",
+		"include_deleted": "include_deleted=maybe",
+		"updated_since":   "updated_since=2021-03-01",
+	}
+
+	for field, raw := range parameters {
+		values, _ := url.ParseQuery(raw)
+
+		_, err := ParseCoffeeQuery(values)
+
+		require.IsType(t, &entities.ValidationError{}, err, field)
+		assert.Equal(t, field, err.(*entities.ValidationError).Field)
+	}
+}
+
+func TestParseIngredientQuery(t *testing.T) {
+	values, _ := url.ParseQuery("include_deleted=true&updated_since=2021-03-01T12:00:00Z")
+
+	query, err := ParseIngredientQuery(values)
+	require.NoError(t, err)
+	assert.True(t, query.IncludeDeleted)
+	assert.Equal(t, time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), *query.UpdatedSince)
+
+	values, _ = url.ParseQuery("include_deleted=maybe")
+
+	_, err = ParseIngredientQuery(values)
+	require.IsType(t, &entities.ValidationError{}, err)
+	assert.Equal(t, "include_deleted", err.(*entities.ValidationError).Field)
+}
+
+func TestSortKeysEndWithID(t *testing.T) {
+	assert.Equal(t, []Sort{{Field: SortByID}}, CoffeeQuery{}.sortKeys())
+	assert.Equal(t, []Sort{{Field: SortByName}, {Field: SortByID}}, CoffeeQuery{Sort: []Sort{{Field: SortByName}}}.sortKeys())
+	assert.Equal(t, []Sort{{Field: SortByID, Descending: true}}, CoffeeQuery{Sort: []Sort{{Field: SortByID, Descending: true}, {Field: SortByName}}}.sortKeys())
+}
+
+func TestCursorRoundTrips(t *testing.T) {
+	coffee := entities.Coffee{ID: 3, Name: "Nomadicano", Price: 150}
+
+	decoded, err := decodeCursor(encodeCursor(coffee))
+	require.NoError(t, err)
+
+	assert.Equal(t, cursorFor(coffee), decoded)
+}
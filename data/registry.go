@@ -0,0 +1,67 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// Factory creates the Repository of a database backend from the service
+// configuration.
+type Factory func(cfg *config.Config) (Repository, error)
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = map[config.BackendKey]Factory{
+		config.Postgres: NewFromConfig,
+		config.Memory:   NewInMemoryDB,
+		config.SQLite:   NewSQLite,
+	}
+)
+
+// Register makes a Factory available for a backend, replacing any Factory
+// already registered for it.
+func Register(backend config.BackendKey, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	factories[backend] = factory
+}
+
+// Backends returns the names of the registered backends in sorted order.
+func Backends() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	backends := make([]string, 0, len(factories))
+	for backend := range factories {
+		backends = append(backends, backend.String())
+	}
+	sort.Strings(backends)
+
+	return backends
+}
+
+// NewRepository is the Repository factory method. It creates the Repository of
+// the backend selected by cfg.DBBackend, and returns an error if no Factory is
+// registered for it.
+func NewRepository(cfg *config.Config) (Repository, error) {
+	factoriesMutex.RLock()
+	factory, ok := factories[cfg.DBBackend]
+	factoriesMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database backend %q, expected one of %s", cfg.DBBackend, strings.Join(Backends(), ", "))
+	}
+
+	cfg.Logger.Debug("Loading repository", "backend", cfg.DBBackend)
+	repository, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s repository: %w", cfg.DBBackend, err)
+	}
+
+	return repository, nil
+}
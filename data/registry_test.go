@@ -0,0 +1,62 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+func TestNewRepositoryCreatesTheConfiguredBackend(t *testing.T) {
+	repository, err := NewRepository(&config.Config{Logger: hclog.NewNullLogger(), DBBackend: config.Memory})
+	require.NoError(t, err)
+	assert.IsType(t, &InMemoryRepository{}, repository)
+
+	repository, err = NewRepository(&config.Config{
+		Logger:     hclog.NewNullLogger(),
+		DBBackend:  config.SQLite,
+		SQLitePath: t.TempDir() + "/coffee-service.db",
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &SQLiteRepository{}, repository)
+}
+
+func TestNewRepositoryReturnsErrorForUnknownBackend(t *testing.T) {
+	repository, err := NewRepository(&config.Config{Logger: hclog.NewNullLogger(), DBBackend: config.BackendKey("mysql")})
+
+	assert.Nil(t, repository)
+	assert.EqualError(t, err, `unknown database backend "mysql", expected one of memory, postgres, sqlite`)
+}
+
+func TestNewRepositoryUsesRegisteredFactory(t *testing.T) {
+	backend := config.BackendKey("test")
+	mock := &MockRepository{}
+	Register(backend, func(cfg *config.Config) (Repository, error) { return mock, nil })
+	defer func() {
+		factoriesMutex.Lock()
+		delete(factories, backend)
+		factoriesMutex.Unlock()
+	}()
+
+	repository, err := NewRepository(&config.Config{Logger: hclog.NewNullLogger(), DBBackend: backend})
+	require.NoError(t, err)
+	assert.Same(t, mock, repository)
+}
+
+func TestNewRepositoryWrapsFactoryErrors(t *testing.T) {
+	backend := config.BackendKey("failing")
+	failure := errors.New("connection refused")
+	Register(backend, func(cfg *config.Config) (Repository, error) { return nil, failure })
+	defer func() {
+		factoriesMutex.Lock()
+		delete(factories, backend)
+		factoriesMutex.Unlock()
+	}()
+
+	_, err := NewRepository(&config.Config{Logger: hclog.NewNullLogger(), DBBackend: backend})
+	assert.True(t, errors.Is(err, failure))
+}
@@ -1,67 +1,217 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
-
-	// otlog "github.com/opentracing/opentracing-go/log"
-	"contrib.go.opencensus.io/integrations/ocsql"
+	"github.com/lib/pq"
 
 	"github.com/hashicorp-demoapp/coffee-service/config"
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
 )
 
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a command would break a reference held by
+	// another record, or restore a reference to a deleted record.
+	ErrConflict = errors.New("record is still referenced")
+	// ErrUnavailable is returned when the database cannot serve the request.
+	ErrUnavailable = errors.New("database is unavailable")
+)
+
+// IsRequestError reports whether the error describes a request the repository
+// refused, a missing record, a conflict or an invalid entity, rather than a
+// failure of the database.
+func IsRequestError(err error) bool {
+	var verr *entities.ValidationError
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) || errors.As(err, &verr)
+}
+
+const (
+	// coffeeColumns are the columns of the coffee table read into entities.Coffee
+	coffeeColumns = "id, name, teaser, description, price, image, created_at, updated_at, deleted_at"
+	// coffeeIngredientColumns are the columns of the coffee_ingredient table,
+	// joined with the ingredient it uses, read into entities.CoffeeIngredients
+	coffeeIngredientColumns = `coffee_ingredient.id, coffee_ingredient.coffee_id, coffee_ingredient.ingredient_id, coffee_ingredient.quantity,
+		coffee_ingredient.unit, coffee_ingredient.created_at, coffee_ingredient.updated_at, coffee_ingredient.deleted_at,
+		ingredient.name AS ingredient_name, ingredient.deleted_at AS ingredient_deleted_at`
+	// ingredientColumns are the columns of the ingredient table read into
+	// entities.Ingredient
+	ingredientColumns = "id, name, created_at, updated_at, deleted_at"
+)
+
+// currentTime returns the timestamp written to the rows. It is in UTC, and
+// truncated to the microsecond precision of Postgres, so that every backend
+// stores and returns the same value.
+func currentTime() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
 // Repository is the command/query interface this respository supports.
 type Repository interface {
-	Find() (entities.Coffees, error)
+	Find(ctx context.Context, query CoffeeQuery) (*CoffeePage, error)
+	FindByID(ctx context.Context, id int, includeDeleted bool) (*entities.Coffee, error)
+	FindIngredients(ctx context.Context, coffeeID int) (entities.Ingredients, error)
+	CreateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error)
+	UpdateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error)
+	DeleteCoffee(ctx context.Context, id int) error
+	RestoreCoffee(ctx context.Context, id int) (*entities.Coffee, error)
+	ListIngredients(ctx context.Context, query IngredientQuery) (entities.Ingredients, error)
+	FindIngredientByID(ctx context.Context, id int, includeDeleted bool) (*entities.Ingredient, error)
+	CreateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error)
+	UpdateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error)
+	DeleteIngredient(ctx context.Context, id int) error
+	Purge(ctx context.Context, before time.Time) (int, error)
+	Revision(ctx context.Context) (time.Time, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// sqlRepository implements the Repository interface for the SQL databases
+// supported by the service. The SQL that differs between them is provided by
+// the dialect.
+type sqlRepository struct {
+	db      *sqlx.DB
+	dialect dialect
+}
+
+// dialect holds the SQL that differs between the databases sqlRepository
+// runs against.
+type dialect struct {
+	// like is the case insensitive pattern matching operator
+	like string
+	// likeEscape declares backslash as the escape character of like patterns
+	// when it is not the default
+	likeEscape string
+	// bytewise is the collation comparing text byte by byte
+	bytewise string
+	// anyOf returns the condition matching column against any of ids, and
+	// the argument to bind to placeholder n
+	anyOf func(column string, n int, ids []int64) (string, interface{})
+	// lock and unlock take and release the lock serializing the schema
+	// migrations of the replicas, empty when the database needs none
+	lock   string
+	unlock string
+	// forUpdate and forShare lock the rows read by a query until the end of
+	// the transaction, empty when the database runs one transaction at a time
+	forUpdate string
+	forShare  string
+	// resetSequence is the format of the statement moving the id sequence of
+	// a table past its rows inserted with explicit ids, empty when the
+	// database does it
+	resetSequence string
+}
+
+// postgresDialect is the dialect of PostgreSQL
+var postgresDialect = dialect{
+	like:     "ILIKE",
+	bytewise: `"C"`,
+	anyOf: func(column string, n int, ids []int64) (string, interface{}) {
+		return fmt.Sprintf("%s = ANY($%d)", column, n), pq.Array(ids)
+	},
+	lock:          "SELECT pg_advisory_lock($1)",
+	unlock:        "SELECT pg_advisory_unlock($1)",
+	forUpdate:     " FOR UPDATE",
+	forShare:      " FOR SHARE",
+	resetSequence: "SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), (SELECT COALESCE(max(id), 0) + 1 FROM %[1]s), false)",
+}
+
+// StatsReporter is implemented by the repositories backed by a database/sql
+// connection pool.
+type StatsReporter interface {
+	Stats() sql.DBStats
+}
+
+// Stats returns the statistics of the connection pool
+func (r *sqlRepository) Stats() sql.DBStats {
+	return r.db.Stats()
+}
+
+// Ping verifies a connection to the database can be established
+func (r *sqlRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Close closes the connections of the pool, waiting for the queries in flight
+// to complete
+func (r *sqlRepository) Close() error {
+	return r.db.Close()
 }
 
 // PostgresRepository is a postgres implementation of the Repository interface.
 type PostgresRepository struct {
-	db *sqlx.DB
+	*sqlRepository
+}
+
+// newPostgresRepository wraps a connection to a Postgres database
+func newPostgresRepository(db *sqlx.DB) *PostgresRepository {
+	return &PostgresRepository{&sqlRepository{db: db, dialect: postgresDialect}}
 }
 
 // NewFromConfig is the CoffeeRepository factory method. It encapsulates the Postgres DB.
-// It will attempt to create a connection, and keep retrying the database connection
-// until successful or times out. When running the application on a scheduler it
-// is possible (likely) that the app will come up before the database, this can
-// cause the app to go into a CrashLoopBackoff cycle. By defining a retry loop,
-// we are implementing circuit breaker, rather than just crashing on startup
-// if the db is unavailable.
-// TODO: this whole thing needs to be addressed.  Probably we want to move
-// the circuit breaking back to the lifecycle in main, and have this just
-// test IsConnected() or just try to make the call.
+// When running the application on a scheduler it is possible (likely) that the
+// app will come up before the database, this can cause the app to go into a
+// CrashLoopBackoff cycle. The connection is therefore retried with an exponential
+// backoff until the database answers or the max wait of the configuration elapses.
+// In degraded mode the repository is returned immediately and connects in the
+// background, failing every call with ErrUnavailable until then.
 func NewFromConfig(cfg *config.Config) (Repository, error) {
-	st := time.Now()
-	dt := 1 * time.Second  // this should be an exponential backoff
-	mt := 60 * time.Second // max time to wait of the DB connection
-
-	for {
-		var repository *PostgresRepository
-		var err error
-
-		if cfg.DBTraceEnabled {
-			repository, err = newPostgresWithTracing(cfg.ConnectionString)
-		} else {
-			repository, err = newPostgres(cfg.ConnectionString)
-		}
-		if err == nil {
+	c := &connector{
+		dial: func() (Repository, error) {
+			repository, err := dialPostgres(cfg)
+			if err != nil {
+				return nil, err
+			}
+
+			if cfg.MigrateOnStart {
+				// The advisory lock makes the replicas starting together wait
+				// for the first one to migrate
+				migrator := newMigrator(repository.db, postgresDialect, migrations, cfg.Logger)
+				if err := migrator.Up(context.Background()); err != nil {
+					repository.Close()
+					return nil, err
+				}
+			}
+
 			return repository, nil
-		}
+		},
+		backoff: newBackoff(cfg.DBConnect),
+		clock:   realClock{},
+		logger:  cfg.Logger,
+	}
 
-		cfg.Logger.Error("Unable to connect to database", "error", err)
+	if cfg.DBConnect.Degraded {
+		cfg.Logger.Info("Starting in degraded mode until connected to database")
+		return newReconnectingRepository(c), nil
+	}
 
-		// check if max time has elapsed
-		if time.Now().Sub(st) > mt {
-			return nil, err
-		}
+	return c.connect(context.Background(), cfg.DBConnect.MaxWait)
+}
 
-		// retry
-		time.Sleep(dt)
+// dialPostgres connects to the database of the configuration with its pool
+// settings
+func dialPostgres(cfg *config.Config) (*PostgresRepository, error) {
+	repository, err := newPostgres(cfg.ConnectionString)
+	if err != nil {
+		return nil, err
 	}
+
+	configurePool(repository.db, cfg.Postgres.Pool)
+	return repository, nil
+}
+
+// configurePool applies the pool settings of the configuration to the
+// connection pool
+func configurePool(db *sqlx.DB, pool config.DBPool) {
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
 }
 
 // new creates a new connection to the database
@@ -71,53 +221,589 @@ func newPostgres(connection string) (*PostgresRepository, error) {
 		return nil, err
 	}
 
-	return &PostgresRepository{db: db}, nil
+	return newPostgresRepository(db), nil
 }
 
-// newWithTracing wraps the connection with OpenCensus instrumentation
-// to allow db query inspection from OpenCensus compliant backends.
-func newPostgresWithTracing(connection string) (*PostgresRepository, error) {
-	// Lifted from here:  https://github.com/opencensus-integrations/ocsql#jmoironsqlx
-	// Register our ocsql wrapper for the provided Postgres driver.
-	driverName, err := ocsql.Register("postgres", ocsql.WithAllTraceOptions())
+// Find returns the page of products from the database matching the query
+func (r *sqlRepository) Find(ctx context.Context, query CoffeeQuery) (*CoffeePage, error) {
+	filters, args := r.dialect.coffeeFilters(query)
+	keys := query.sortKeys()
+	paginated := query.Limit > 0 || query.Cursor != ""
+
+	page := &CoffeePage{}
+
+	if paginated {
+		err := r.db.GetContext(ctx, &page.Total, "SELECT count(*) FROM coffee"+where(filters), args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, &entities.ValidationError{Field: "cursor", Reason: "is not valid"}
+		}
+
+		var condition string
+		condition, args = r.dialect.keysetCondition(keys, after, args)
+		filters = append(filters, condition)
+	}
+
+	statement := "SELECT " + coffeeColumns + " FROM coffee" + where(filters) + r.dialect.orderBy(keys)
+	if query.Limit > 0 {
+		// Read one extra row to find out if there is another page
+		args = append(args, query.Limit+1)
+		statement += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	coffees := entities.Coffees{}
+
+	err := r.db.SelectContext(ctx, &coffees, statement, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Connect to a Postgres database using the ocsql driver wrapper.
-	// TODO: Test this - might need to use url format conn string like so
-	// "postgres://localhost:5432/my_database"
-	db, err := sql.Open(driverName, connection)
-	if err != nil {
+	if query.Limit > 0 && len(coffees) > query.Limit {
+		coffees = coffees[:query.Limit]
+		page.NextCursor = encodeCursor(coffees[len(coffees)-1])
+	}
+
+	if !paginated {
+		page.Total = len(coffees)
+	}
+
+	if err = r.loadCoffeeIngredients(ctx, coffees); err != nil {
 		return nil, err
 	}
 
-	// Wrap our *sql.DB with sqlx. use the original db driver name!!!
-	dbx := sqlx.NewDb(db, "postgres")
+	page.Coffees = coffees
 
-	return &PostgresRepository{dbx}, nil
+	return page, nil
 }
 
-// Find returns all products from the database
-// Used to accept ctx opentracing.SpanContext
-func (r *PostgresRepository) Find() (entities.Coffees, error) {
-	coffees := entities.Coffees{}
+// FindByID returns a single coffee from the database, or ErrNotFound
+// if no coffee exists with the given id or it is deleted and includeDeleted is
+// false.
+func (r *sqlRepository) FindByID(ctx context.Context, id int, includeDeleted bool) (*entities.Coffee, error) {
+	coffees := entities.Coffees{entities.Coffee{}}
+
+	statement := "SELECT " + coffeeColumns + " FROM coffee WHERE id=$1"
+	if !includeDeleted {
+		statement += " AND deleted_at IS NULL"
+	}
 
-	err := r.db.Select(&coffees, "SELECT * FROM coffee")
+	err := r.db.GetContext(ctx, &coffees[0], statement, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if err = r.loadCoffeeIngredients(ctx, coffees); err != nil {
+		return nil, err
+	}
+
+	return &coffees[0], nil
+}
+
+// loadCoffeeIngredients sets the Ingredients of every coffee, with the name and
+// deletion of the ingredients they use, using a single query, so the number of
+// queries does not grow with the size of the menu.
+func (r *sqlRepository) loadCoffeeIngredients(ctx context.Context, coffees entities.Coffees) error {
+	if len(coffees) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(coffees))
+	positions := make(map[int]int, len(coffees))
+
 	for n, coffee := range coffees {
-		coffeeIngredients := []entities.CoffeeIngredients{}
+		ids[n] = int64(coffee.ID)
+		positions[coffee.ID] = n
+		coffees[n].Ingredients = []entities.CoffeeIngredients{}
+	}
+
+	coffeeIngredients := []entities.CoffeeIngredients{}
+	condition, arg := r.dialect.anyOf("coffee_ingredient.coffee_id", 1, ids)
+
+	err := r.db.SelectContext(ctx, &coffeeIngredients, `SELECT `+coffeeIngredientColumns+`
+		FROM coffee_ingredient
+		INNER JOIN ingredient ON ingredient.id = coffee_ingredient.ingredient_id
+		WHERE `+condition+`
+		ORDER BY coffee_ingredient.id`, arg)
+	if err != nil {
+		return err
+	}
+
+	for _, ci := range coffeeIngredients {
+		n := positions[ci.CoffeeID]
+		coffees[n].Ingredients = append(coffees[n].Ingredients, ci)
+	}
+
+	return nil
+}
+
+// FindIngredients returns the ingredients, with the quantity and unit used by
+// the recipe, for the coffee with the given id. The ingredients of a coffee
+// that is not deleted cannot be deleted, DeleteIngredient and RestoreCoffee
+// refuse it. ErrNotFound is returned if no coffee exists with the given id or
+// it is deleted.
+func (r *sqlRepository) FindIngredients(ctx context.Context, coffeeID int) (entities.Ingredients, error) {
+	var id int
+
+	err := r.db.GetContext(ctx, &id, "SELECT id FROM coffee WHERE id=$1 AND deleted_at IS NULL", coffeeID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ingredients := entities.Ingredients{}
+
+	err = r.db.SelectContext(ctx, &ingredients, `SELECT ingredient.id, ingredient.name, coffee_ingredient.quantity, coffee_ingredient.unit
+		FROM coffee_ingredient
+		INNER JOIN ingredient ON ingredient.id = coffee_ingredient.ingredient_id
+		WHERE coffee_ingredient.coffee_id=$1
+		ORDER BY coffee_ingredient.id`, coffeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ingredients, nil
+}
+
+// CreateCoffee inserts the coffee and its coffee_ingredient rows in a single
+// transaction, and returns the stored coffee.
+func (r *sqlRepository) CreateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+
+	timestamp := currentTime()
+
+	err = tx.GetContext(ctx, &id, `INSERT INTO coffee (name, teaser, description, price, image, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id`, coffee.Name, coffee.Teaser, coffee.Description, coffee.Price, coffee.Image, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.insertCoffeeIngredients(ctx, tx, id, coffee.Ingredients, timestamp); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(ctx, id, false)
+}
+
+// UpdateCoffee replaces the coffee with the matching id, including its
+// coffee_ingredient rows, in a single transaction. ErrNotFound is returned if
+// no coffee exists with the given id or it is deleted.
+func (r *sqlRepository) UpdateCoffee(ctx context.Context, coffee entities.Coffee) (*entities.Coffee, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	timestamp := currentTime()
+
+	result, err := tx.ExecContext(ctx, `UPDATE coffee
+		SET name=$1, teaser=$2, description=$3, price=$4, image=$5, updated_at=$6
+		WHERE id=$7 AND deleted_at IS NULL`, coffee.Name, coffee.Teaser, coffee.Description, coffee.Price, coffee.Image, timestamp, coffee.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, ErrNotFound
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM coffee_ingredient WHERE coffee_id=$1", coffee.ID); err != nil {
+		return nil, err
+	}
+
+	if err = r.insertCoffeeIngredients(ctx, tx, coffee.ID, coffee.Ingredients, timestamp); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(ctx, coffee.ID, false)
+}
 
-		err := r.db.Select(&coffeeIngredients, "SELECT ingredient_id FROM coffee_ingredient WHERE coffee_id=$1", coffee.ID)
+// DeleteCoffee soft deletes the coffee, keeping its recipe so that it can be
+// restored until purged. ErrNotFound is returned if no coffee exists with the
+// given id or it is already deleted.
+func (r *sqlRepository) DeleteCoffee(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE coffee SET deleted_at=$1, updated_at=$1 WHERE id=$2 AND deleted_at IS NULL", currentTime(), id)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RestoreCoffee undoes the soft delete of the coffee, and returns it.
+// Restoring a coffee that is not deleted returns it unchanged. ErrConflict is
+// returned if the recipe uses an ingredient deleted since, and ErrNotFound if
+// no coffee exists with the given id, or it has been purged.
+func (r *sqlRepository) RestoreCoffee(ctx context.Context, id int) (*entities.Coffee, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Locking the ingredients of the recipe waits for a concurrent
+	// DeleteIngredient, which then sees the restored coffee
+	deletedAt := []sql.NullTime{}
+
+	err = tx.SelectContext(ctx, &deletedAt, `SELECT deleted_at FROM ingredient WHERE id IN (
+		SELECT coffee_ingredient.ingredient_id FROM coffee_ingredient
+		INNER JOIN coffee ON coffee.id = coffee_ingredient.coffee_id
+		WHERE coffee.id=$1 AND coffee.deleted_at IS NOT NULL)`+r.dialect.forShare, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, deleted := range deletedAt {
+		if deleted.Valid {
+			return nil, ErrConflict
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE coffee SET deleted_at=NULL, updated_at=$1 WHERE id=$2 AND deleted_at IS NOT NULL", currentTime(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.FindByID(ctx, id, false)
+}
+
+// Purge hard deletes the coffees, with their recipes, and the ingredients
+// deleted before the time, in a single transaction. Deleted ingredients still
+// used by the recipe of a coffee are kept. It returns the number of coffees
+// and ingredients purged.
+func (r *sqlRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := before.UTC()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM coffee_ingredient WHERE coffee_id IN (SELECT id FROM coffee WHERE deleted_at < $1)", cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+
+	for _, statement := range []string{
+		"DELETE FROM coffee WHERE deleted_at < $1",
+		"DELETE FROM ingredient WHERE deleted_at < $1 AND id NOT IN (SELECT ingredient_id FROM coffee_ingredient)",
+	} {
+		result, err := tx.ExecContext(ctx, statement, cutoff)
 		if err != nil {
-			return nil, err
+			return 0, err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += int(rows)
+	}
+
+	return purged, tx.Commit()
+}
+
+// Revision returns the time of the latest change to the coffees and the
+// ingredients, deleted rows included, or zero when the database is empty.
+func (r *sqlRepository) Revision(ctx context.Context) (time.Time, error) {
+	var revision time.Time
+
+	for _, table := range []string{"coffee", "ingredient"} {
+		// Sorting keeps the type of the column, SQLite returns the max of
+		// timestamps as text
+		updated := []time.Time{}
+		err := r.db.SelectContext(ctx, &updated, "SELECT updated_at FROM "+table+" ORDER BY updated_at DESC LIMIT 1")
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if len(updated) > 0 && updated[0].After(revision) {
+			revision = updated[0]
+		}
+	}
+
+	return revision, nil
+}
+
+// coffeeFilters returns the SQL conditions, and their arguments, for the
+// filters of the query.
+func (d dialect) coffeeFilters(query CoffeeQuery) ([]string, []interface{}) {
+	filters := []string{}
+	args := []interface{}{}
+
+	if !query.IncludeDeleted {
+		filters = append(filters, "deleted_at IS NULL")
+	}
+
+	if query.MaxPrice != nil {
+		args = append(args, *query.MaxPrice)
+		filters = append(filters, fmt.Sprintf("price <= $%d", len(args)))
+	}
+
+	if query.IngredientID != 0 {
+		args = append(args, query.IngredientID)
+		filters = append(filters, fmt.Sprintf("id IN (SELECT coffee_id FROM coffee_ingredient WHERE ingredient_id = $%d)", len(args)))
+	}
+
+	if query.UpdatedSince != nil {
+		args = append(args, *query.UpdatedSince)
+		filters = append(filters, fmt.Sprintf("updated_at > $%d", len(args)))
+	}
+
+	if query.NameContains != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query.NameContains)
+		args = append(args, "%"+escaped+"%")
+		filters = append(filters, fmt.Sprintf("name %s $%d%s", d.like, len(args), d.likeEscape))
+	}
+
+	return filters, args
+}
+
+// keysetCondition returns the SQL condition selecting the coffees sorted after
+// the cursor, appending its arguments to args.
+func (d dialect) keysetCondition(keys []Sort, after cursor, args []interface{}) (string, []interface{}) {
+	alternatives := []string{}
+	equal := []string{}
+
+	for _, key := range keys {
+		var value interface{}
+		switch key.Field {
+		case SortByID:
+			value = after.ID
+		case SortByName:
+			value = after.Name
+		case SortByPrice:
+			value = after.Price
 		}
+		args = append(args, value)
+
+		operator := ">"
+		if key.Descending {
+			operator = "<"
+		}
+
+		column := d.sortColumn(key.Field)
+		terms := append(append([]string{}, equal...), fmt.Sprintf("%s %s $%d", column, operator, len(args)))
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		equal = append(equal, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
 
-		coffees[n].Ingredients = coffeeIngredients
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// sortColumn returns the SQL expression for a sort field. Names are compared
+// bytewise to match the ordering of the in memory repository.
+func (d dialect) sortColumn(field SortField) string {
+	if field == SortByName {
+		return "name COLLATE " + d.bytewise
+	}
+
+	return field.String()
+}
+
+func (d dialect) orderBy(keys []Sort) string {
+	columns := make([]string, len(keys))
+
+	for n, key := range keys {
+		columns[n] = d.sortColumn(key.Field)
+		if key.Descending {
+			columns[n] += " DESC"
+		}
+	}
+
+	return " ORDER BY " + strings.Join(columns, ", ")
+}
+
+func where(filters []string) string {
+	if len(filters) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(filters, " AND ")
+}
+
+// insertCoffeeIngredients writes the recipe for a coffee, checking that each
+// referenced ingredient exists and is not deleted.
+func (r *sqlRepository) insertCoffeeIngredients(ctx context.Context, tx *sqlx.Tx, coffeeID int, coffeeIngredients []entities.CoffeeIngredients, timestamp time.Time) error {
+	for _, ci := range coffeeIngredients {
+		var id int
+
+		// The lock keeps the ingredient from being deleted until the recipe is
+		// committed
+		err := tx.GetContext(ctx, &id, "SELECT id FROM ingredient WHERE id=$1 AND deleted_at IS NULL"+r.dialect.forShare, ci.IngredientID)
+		if err == sql.ErrNoRows {
+			return &entities.ValidationError{Field: "ingredients", Reason: fmt.Sprintf("references unknown ingredient_id %d", ci.IngredientID)}
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO coffee_ingredient (coffee_id, ingredient_id, quantity, unit, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $5)`, coffeeID, ci.IngredientID, ci.Quantity, ci.Unit, timestamp)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListIngredients returns the ingredient catalog matching the query
+func (r *sqlRepository) ListIngredients(ctx context.Context, query IngredientQuery) (entities.Ingredients, error) {
+	ingredients := entities.Ingredients{}
+
+	filters := []string{}
+	args := []interface{}{}
+
+	if !query.IncludeDeleted {
+		filters = append(filters, "deleted_at IS NULL")
+	}
+
+	if query.UpdatedSince != nil {
+		args = append(args, *query.UpdatedSince)
+		filters = append(filters, fmt.Sprintf("updated_at > $%d", len(args)))
+	}
+
+	err := r.db.SelectContext(ctx, &ingredients, "SELECT "+ingredientColumns+" FROM ingredient"+where(filters)+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ingredients, nil
+}
+
+// FindIngredientByID returns a single ingredient from the catalog, or
+// ErrNotFound if no ingredient exists with the given id or it is deleted and
+// includeDeleted is false.
+func (r *sqlRepository) FindIngredientByID(ctx context.Context, id int, includeDeleted bool) (*entities.Ingredient, error) {
+	ingredient := entities.Ingredient{}
+
+	statement := "SELECT " + ingredientColumns + " FROM ingredient WHERE id=$1"
+	if !includeDeleted {
+		statement += " AND deleted_at IS NULL"
+	}
+
+	err := r.db.GetContext(ctx, &ingredient, statement, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ingredient, nil
+}
+
+// CreateIngredient adds an ingredient to the catalog
+func (r *sqlRepository) CreateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error) {
+	var id int
+
+	err := r.db.GetContext(ctx, &id, "INSERT INTO ingredient (name, created_at, updated_at) VALUES ($1, $2, $2) RETURNING id", ingredient.Name, currentTime())
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindIngredientByID(ctx, id, false)
+}
+
+// UpdateIngredient replaces the ingredient with the matching id. ErrNotFound
+// is returned if no ingredient exists with the given id or it is deleted.
+func (r *sqlRepository) UpdateIngredient(ctx context.Context, ingredient entities.Ingredient) (*entities.Ingredient, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE ingredient SET name=$1, updated_at=$2 WHERE id=$3 AND deleted_at IS NULL", ingredient.Name, currentTime(), ingredient.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, ErrNotFound
+	}
+
+	return r.FindIngredientByID(ctx, ingredient.ID, false)
+}
+
+// DeleteIngredient soft deletes an ingredient of the catalog. ErrConflict is
+// returned if a coffee that is not deleted still uses the ingredient, and
+// ErrNotFound if no ingredient exists with the given id or it is already
+// deleted.
+func (r *sqlRepository) DeleteIngredient(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var locked int
+
+	// Locking the ingredient first waits for the transactions adding it to a
+	// recipe, so the references are counted once they are committed
+	err = tx.GetContext(ctx, &locked, "SELECT id FROM ingredient WHERE id=$1 AND deleted_at IS NULL"+r.dialect.forUpdate, id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var references int
+
+	err = tx.GetContext(ctx, &references, `SELECT count(*) FROM coffee_ingredient
+		INNER JOIN coffee ON coffee.id = coffee_ingredient.coffee_id
+		WHERE coffee_ingredient.ingredient_id=$1 AND coffee.deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+
+	if references > 0 {
+		return ErrConflict
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE ingredient SET deleted_at=$1, updated_at=$1 WHERE id=$2", currentTime(), id)
+	if err != nil {
+		return err
 	}
 
-	return coffees, nil
+	return tx.Commit()
 }
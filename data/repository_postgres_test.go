@@ -0,0 +1,208 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// setupMockPostgres returns a PostgresRepository backed by sqlmock. The
+// returned counter is incremented for every query the repository executes.
+func setupMockPostgres(t testing.TB) (*PostgresRepository, sqlmock.Sqlmock, *int) {
+	queries := 0
+	matcher := sqlmock.QueryMatcherFunc(func(expected, actual string) error {
+		queries++
+		return sqlmock.QueryMatcherRegexp.Match(expected, actual)
+	})
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	require.NoError(t, err)
+
+	return newPostgresRepository(sqlx.NewDb(db, "postgres")), mock, &queries
+}
+
+// expectMenu sets up the queries for a menu of size coffees, each with two ingredients.
+func expectMenu(mock sqlmock.Sqlmock, size int) {
+	coffees := sqlmock.NewRows([]string{"id", "name", "teaser", "description", "price", "image", "created_at", "updated_at", "deleted_at"})
+	coffeeIngredients := sqlmock.NewRows([]string{"id", "coffee_id", "ingredient_id", "quantity", "unit", "created_at", "updated_at", "deleted_at", "ingredient_name", "ingredient_deleted_at"})
+
+	timestamp := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for id := 1; id <= size; id++ {
+		coffees.AddRow(id, fmt.Sprintf("Coffee %d", id), "", "", 200, "", timestamp, timestamp, nil)
+		coffeeIngredients.AddRow(id*2-1, id, 1, 40, "ml", timestamp, timestamp, nil, "Espresso", nil)
+		coffeeIngredients.AddRow(id*2, id, 2, 300, "ml", timestamp, timestamp, nil, "Steamed Milk", nil)
+	}
+
+	mock.ExpectQuery(`SELECT id, name, .* FROM coffee WHERE deleted_at IS NULL ORDER BY id`).WillReturnRows(coffees)
+	mock.ExpectQuery(`SELECT coffee_ingredient.id, .* FROM coffee_ingredient INNER JOIN ingredient .* WHERE coffee_ingredient.coffee_id = ANY\(\$1\)`).WithArgs(sqlmock.AnyArg()).WillReturnRows(coffeeIngredients)
+}
+
+func TestPostgresFindLoadsIngredientsInOneQuery(t *testing.T) {
+	for _, size := range []int{1, 10, 500} {
+		t.Run(fmt.Sprintf("%d coffees", size), func(t *testing.T) {
+			repository, mock, queries := setupMockPostgres(t)
+			expectMenu(mock, size)
+
+			page, err := repository.Find(context.Background(), CoffeeQuery{})
+			require.NoError(t, err)
+			coffees := page.Coffees
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			assert.Equal(t, 2, *queries)
+			assert.Len(t, coffees, size)
+
+			for _, coffee := range coffees {
+				require.Len(t, coffee.Ingredients, 2)
+				assert.Equal(t, coffee.ID, coffee.Ingredients[0].CoffeeID)
+				assert.Equal(t, coffee.ID, coffee.Ingredients[1].CoffeeID)
+				assert.Equal(t, "Espresso", coffee.Ingredients[0].IngredientName)
+			}
+		})
+	}
+}
+
+func TestPostgresFindSkipsIngredientsQueryForEmptyMenu(t *testing.T) {
+	repository, mock, queries := setupMockPostgres(t)
+	mock.ExpectQuery(`SELECT id, name, .* FROM coffee WHERE deleted_at IS NULL ORDER BY id`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	page, err := repository.Find(context.Background(), CoffeeQuery{})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, 1, *queries)
+	assert.Empty(t, page.Coffees)
+}
+
+// BenchmarkPostgresFind reports the number of queries Find executes per call,
+// which stays constant as the menu grows.
+func BenchmarkPostgresFind(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("%d coffees", size), func(b *testing.B) {
+			repository, mock, queries := setupMockPostgres(b)
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				expectMenu(mock, size)
+				b.StartTimer()
+
+				if _, err := repository.Find(context.Background(), CoffeeQuery{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(*queries)/float64(b.N), "queries/op")
+		})
+	}
+}
+
+func TestPostgresFindIsCancelledWithContext(t *testing.T) {
+	repository, mock, _ := setupMockPostgres(t)
+	mock.ExpectQuery(`SELECT id, name, .* FROM coffee WHERE deleted_at IS NULL ORDER BY id`).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := repository.Find(ctx, CoffeeQuery{})
+
+	assert.Error(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestPostgresFindBuildsPaginatedQuery(t *testing.T) {
+	repository, mock, queries := setupMockPostgres(t)
+	maxPrice := 200.0
+	after := encodeCursor(entities.Coffee{ID: 2, Name: "Vaulatte", Price: 200})
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM coffee WHERE deleted_at IS NULL AND price <= \$1 AND name ILIKE \$2`).
+		WithArgs(maxPrice, "%latte%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT id, name, .* FROM coffee WHERE deleted_at IS NULL AND price <= \$1 AND name ILIKE \$2 AND \(\(price < \$3\) OR \(price = \$3 AND id > \$4\)\) ORDER BY price DESC, id LIMIT \$5`).
+		WithArgs(maxPrice, "%latte%", 200.0, 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price"}).AddRow(5, "Cheap Latte", 100).AddRow(6, "Cheaper Latte", 50))
+	mock.ExpectQuery(`FROM coffee_ingredient INNER JOIN ingredient .* WHERE coffee_ingredient.coffee_id = ANY\(\$1\)`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "coffee_id"}))
+
+	page, err := repository.Find(context.Background(), CoffeeQuery{
+		Limit:        1,
+		Cursor:       after,
+		Sort:         []Sort{{Field: SortByPrice, Descending: true}},
+		MaxPrice:     &maxPrice,
+		NameContains: "latte",
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, 3, *queries)
+	assert.Equal(t, 3, page.Total)
+	assert.Len(t, page.Coffees, 1)
+	assert.Equal(t, encodeCursor(entities.Coffee{ID: 5, Name: "Cheap Latte", Price: 100}), page.NextCursor)
+}
+
+func TestPostgresDeleteIngredientLocksItBeforeCountingReferences(t *testing.T) {
+	repository, mock, _ := setupMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM ingredient WHERE id=\$1 AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM coffee_ingredient`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`UPDATE ingredient SET deleted_at=\$1, updated_at=\$1 WHERE id=\$2`).
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repository.DeleteIngredient(context.Background(), 3))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresDeleteIngredientReturnsConflictWhenReferenced(t *testing.T) {
+	repository, mock, _ := setupMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM coffee_ingredient`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	assert.Equal(t, ErrConflict, repository.DeleteIngredient(context.Background(), 1))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRestoreCoffeeLocksTheRecipeIngredients(t *testing.T) {
+	repository, mock, _ := setupMockPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT deleted_at FROM ingredient WHERE id IN \(.*\) FOR SHARE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(nil).AddRow(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)))
+	mock.ExpectRollback()
+
+	_, err := repository.RestoreCoffee(context.Background(), 1)
+
+	assert.Equal(t, ErrConflict, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConfigurePoolLimitsConnections(t *testing.T) {
+	repository, _, _ := setupMockPostgres(t)
+
+	configurePool(repository.db, config.DBPool{MaxOpenConns: 3, MaxIdleConns: 1, ConnMaxLifetime: time.Minute})
+
+	assert.Equal(t, 3, repository.db.Stats().MaxOpenConnections)
+}
@@ -0,0 +1,461 @@
+package data
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// postgresTestConnection is the environment variable holding the connection
+// string of a seeded Postgres database. When it is not set the parity tests
+// only run against the in memory repository.
+const postgresTestConnection = "POSTGRES_TEST_CONNECTION"
+
+// setupRepositories returns every Repository implementation that can be
+// reached from the test environment, keyed by name.
+func setupRepositories(t *testing.T) map[string]Repository {
+	repositories := map[string]Repository{}
+
+	memdb, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger()})
+	require.NoError(t, err)
+	repositories["memdb"] = memdb
+
+	sqlite, err := NewSQLite(&config.Config{
+		Logger:     hclog.NewNullLogger(),
+		SQLitePath: filepath.Join(t.TempDir(), "coffee-service.db"),
+	})
+	require.NoError(t, err)
+	repositories["sqlite"] = sqlite
+
+	if connection := os.Getenv(postgresTestConnection); connection != "" {
+		postgres, err := newPostgres(connection)
+		require.NoError(t, err)
+		repositories["postgres"] = postgres
+	}
+
+	return repositories
+}
+
+func TestFindReturnsEachCoffeeWithItsOwnIngredients(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			page, err := repository.Find(context.Background(), CoffeeQuery{})
+			require.NoError(t, err)
+			coffees := page.Coffees
+			require.NotEmpty(t, coffees)
+
+			for _, coffee := range coffees {
+				assert.NotEmpty(t, coffee.Ingredients, "coffee %d has no ingredients", coffee.ID)
+
+				ingredients, err := repository.FindIngredients(context.Background(), coffee.ID)
+				require.NoError(t, err)
+				require.Len(t, coffee.Ingredients, len(ingredients), "coffee %d", coffee.ID)
+
+				for n, ci := range coffee.Ingredients {
+					assert.Equal(t, ingredients[n].ID, ci.IngredientID)
+					assert.Equal(t, ingredients[n].Quantity, ci.Quantity)
+					assert.Equal(t, ingredients[n].Unit, ci.Unit)
+					assert.Equal(t, ingredients[n].Name, ci.IngredientName)
+					assert.False(t, ci.IngredientDeletedAt.Valid)
+				}
+			}
+		})
+	}
+}
+
+func TestPingSucceeds(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, repository.Ping(context.Background()))
+		})
+	}
+}
+
+func TestFindMatchesFindByID(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			page, err := repository.Find(context.Background(), CoffeeQuery{})
+			require.NoError(t, err)
+			coffees := page.Coffees
+
+			for _, coffee := range coffees {
+				found, err := repository.FindByID(context.Background(), coffee.ID, false)
+				require.NoError(t, err)
+				assert.Equal(t, coffee, *found)
+			}
+		})
+	}
+}
+
+func TestFindByIDReturnsErrNotFound(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := repository.FindByID(context.Background(), -1, false)
+			assert.Equal(t, ErrNotFound, err)
+
+			_, err = repository.FindIngredients(context.Background(), -1)
+			assert.Equal(t, ErrNotFound, err)
+		})
+	}
+}
+
+func TestCreateAndDeleteCoffeeRoundTrips(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ingredients, err := repository.ListIngredients(context.Background(), IngredientQuery{})
+			require.NoError(t, err)
+			require.NotEmpty(t, ingredients)
+
+			created, err := repository.CreateCoffee(context.Background(), entities.Coffee{
+				Name:        "Parity Test",
+				Price:       100,
+				Ingredients: []entities.CoffeeIngredients{{IngredientID: ingredients[0].ID, Quantity: 40, Unit: "ml"}},
+			})
+			require.NoError(t, err)
+			require.Len(t, created.Ingredients, 1)
+			assert.Equal(t, ingredients[0].ID, created.Ingredients[0].IngredientID)
+
+			assert.Equal(t, ErrConflict, repository.DeleteIngredient(context.Background(), ingredients[0].ID))
+
+			require.NoError(t, repository.DeleteCoffee(context.Background(), created.ID))
+
+			_, err = repository.FindByID(context.Background(), created.ID, false)
+			assert.Equal(t, ErrNotFound, err)
+			assert.Equal(t, ErrNotFound, repository.DeleteCoffee(context.Background(), created.ID))
+		})
+	}
+}
+
+func TestDeleteCoffeeIsRestoredUntilPurged(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			all, err := repository.Find(ctx, CoffeeQuery{})
+			require.NoError(t, err)
+			coffee := all.Coffees[0]
+
+			require.NoError(t, repository.DeleteCoffee(ctx, coffee.ID))
+
+			page, err := repository.Find(ctx, CoffeeQuery{})
+			require.NoError(t, err)
+			assert.NotContains(t, coffeeIDs(page.Coffees), coffee.ID)
+			assert.Equal(t, all.Total-1, page.Total)
+
+			page, err = repository.Find(ctx, CoffeeQuery{IncludeDeleted: true})
+			require.NoError(t, err)
+			assert.Equal(t, coffeeIDs(all.Coffees), coffeeIDs(page.Coffees))
+
+			_, err = repository.FindByID(ctx, coffee.ID, false)
+			assert.Equal(t, ErrNotFound, err)
+			deleted, err := repository.FindByID(ctx, coffee.ID, true)
+			require.NoError(t, err)
+			assert.True(t, deleted.DeletedAt.Valid)
+			assert.Equal(t, coffee.Ingredients, deleted.Ingredients)
+
+			_, err = repository.FindIngredients(ctx, coffee.ID)
+			assert.Equal(t, ErrNotFound, err)
+			_, err = repository.UpdateCoffee(ctx, coffee)
+			assert.Equal(t, ErrNotFound, err)
+
+			restored, err := repository.RestoreCoffee(ctx, coffee.ID)
+			require.NoError(t, err)
+			assert.Equal(t, coffee.Ingredients, restored.Ingredients)
+			assert.False(t, restored.DeletedAt.Valid)
+
+			// Rows deleted after the cutoff are kept
+			require.NoError(t, repository.DeleteCoffee(ctx, coffee.ID))
+			purged, err := repository.Purge(ctx, time.Now().Add(-time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 0, purged)
+
+			purged, err = repository.Purge(ctx, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+
+			_, err = repository.RestoreCoffee(ctx, coffee.ID)
+			assert.Equal(t, ErrNotFound, err)
+		})
+	}
+}
+
+func TestPurgedIDsAreNotReused(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			coffee, err := repository.CreateCoffee(ctx, entities.Coffee{Name: "Parity Test", Price: 100})
+			require.NoError(t, err)
+
+			require.NoError(t, repository.DeleteCoffee(ctx, coffee.ID))
+			_, err = repository.Purge(ctx, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+
+			created, err := repository.CreateCoffee(ctx, entities.Coffee{Name: "Parity Test", Price: 100})
+			require.NoError(t, err)
+			assert.Greater(t, created.ID, coffee.ID)
+		})
+	}
+}
+
+func TestDeleteIngredientKeepsItForDeletedCoffees(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ingredient, err := repository.CreateIngredient(ctx, entities.Ingredient{Name: "Parity Syrup"})
+			require.NoError(t, err)
+
+			coffee, err := repository.CreateCoffee(ctx, entities.Coffee{
+				Name:        "Parity Test",
+				Price:       100,
+				Ingredients: []entities.CoffeeIngredients{{IngredientID: ingredient.ID, Quantity: 10, Unit: "ml"}},
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, ErrConflict, repository.DeleteIngredient(ctx, ingredient.ID))
+			require.NoError(t, repository.DeleteCoffee(ctx, coffee.ID))
+			require.NoError(t, repository.DeleteIngredient(ctx, ingredient.ID))
+
+			_, err = repository.FindIngredientByID(ctx, ingredient.ID, false)
+			assert.Equal(t, ErrNotFound, err)
+
+			found, err := repository.FindIngredientByID(ctx, ingredient.ID, true)
+			require.NoError(t, err)
+			assert.True(t, found.DeletedAt.Valid)
+
+			deleted, err := repository.FindByID(ctx, coffee.ID, true)
+			require.NoError(t, err)
+			require.Len(t, deleted.Ingredients, 1)
+			assert.Equal(t, "Parity Syrup", deleted.Ingredients[0].IngredientName)
+			assert.True(t, deleted.Ingredients[0].IngredientDeletedAt.Valid)
+
+			// The recipe of a restored coffee would use the deleted ingredient
+			_, err = repository.RestoreCoffee(ctx, coffee.ID)
+			assert.Equal(t, ErrConflict, err)
+			page, err := repository.Find(ctx, CoffeeQuery{})
+			require.NoError(t, err)
+			assert.NotContains(t, coffeeIDs(page.Coffees), coffee.ID)
+
+			ingredients, err := repository.ListIngredients(ctx, IngredientQuery{})
+			require.NoError(t, err)
+			assert.NotContains(t, ingredientIDs(ingredients), ingredient.ID)
+
+			ingredients, err = repository.ListIngredients(ctx, IngredientQuery{IncludeDeleted: true})
+			require.NoError(t, err)
+			assert.Contains(t, ingredientIDs(ingredients), ingredient.ID)
+
+			purged, err := repository.Purge(ctx, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 2, purged)
+
+			ingredients, err = repository.ListIngredients(ctx, IngredientQuery{IncludeDeleted: true})
+			require.NoError(t, err)
+			assert.NotContains(t, ingredientIDs(ingredients), ingredient.ID)
+		})
+	}
+}
+
+func TestRevisionMovesWithDeletesAndIngredientRenames(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			page, err := repository.Find(ctx, CoffeeQuery{})
+			require.NoError(t, err)
+
+			revision, err := repository.Revision(ctx)
+			require.NoError(t, err)
+			assert.False(t, revision.IsZero())
+
+			time.Sleep(time.Millisecond)
+			require.NoError(t, repository.DeleteCoffee(ctx, page.Coffees[0].ID))
+			deleted, err := repository.Revision(ctx)
+			require.NoError(t, err)
+			assert.True(t, deleted.After(revision))
+
+			ingredient := page.Coffees[1].Ingredients[0]
+			time.Sleep(time.Millisecond)
+			_, err = repository.UpdateIngredient(ctx, entities.Ingredient{ID: ingredient.IngredientID, Name: "Parity Syrup"})
+			require.NoError(t, err)
+			renamed, err := repository.Revision(ctx)
+			require.NoError(t, err)
+			assert.True(t, renamed.After(deleted))
+		})
+	}
+}
+
+func TestTimestampsTrackCreationAndUpdates(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			before := time.Now().UTC().Truncate(time.Microsecond)
+
+			created, err := repository.CreateCoffee(ctx, entities.Coffee{Name: "Parity Test", Price: 100})
+			require.NoError(t, err)
+			assert.False(t, created.CreatedAt.Before(before))
+			assert.True(t, created.UpdatedAt.Equal(created.CreatedAt))
+
+			page, err := repository.Find(ctx, CoffeeQuery{UpdatedSince: &before})
+			require.NoError(t, err)
+			assert.Equal(t, []int{created.ID}, coffeeIDs(page.Coffees))
+
+			page, err = repository.Find(ctx, CoffeeQuery{UpdatedSince: &created.UpdatedAt})
+			require.NoError(t, err)
+			assert.Empty(t, page.Coffees)
+
+			time.Sleep(time.Millisecond)
+			updated, err := repository.UpdateCoffee(ctx, entities.Coffee{ID: created.ID, Name: "Parity Test", Price: 200})
+			require.NoError(t, err)
+			assert.True(t, updated.CreatedAt.Equal(created.CreatedAt))
+			assert.True(t, updated.UpdatedAt.After(created.UpdatedAt))
+
+			page, err = repository.Find(ctx, CoffeeQuery{UpdatedSince: &created.UpdatedAt})
+			require.NoError(t, err)
+			assert.Equal(t, []int{created.ID}, coffeeIDs(page.Coffees))
+
+			ingredient, err := repository.CreateIngredient(ctx, entities.Ingredient{Name: "Parity Syrup"})
+			require.NoError(t, err)
+
+			ingredients, err := repository.ListIngredients(ctx, IngredientQuery{UpdatedSince: &before})
+			require.NoError(t, err)
+			assert.Equal(t, []int{ingredient.ID}, ingredientIDs(ingredients))
+		})
+	}
+}
+
+func TestCreateCoffeeRejectsUnknownIngredient(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := repository.CreateCoffee(context.Background(), entities.Coffee{
+				Name:        "Parity Test",
+				Price:       100,
+				Ingredients: []entities.CoffeeIngredients{{IngredientID: -1, Quantity: 40, Unit: "ml"}},
+			})
+
+			assert.IsType(t, &entities.ValidationError{}, err)
+		})
+	}
+}
+
+func TestFindFiltersCoffees(t *testing.T) {
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			all, err := repository.Find(context.Background(), CoffeeQuery{})
+			require.NoError(t, err)
+			require.NotEmpty(t, all.Coffees)
+
+			reference := all.Coffees[0]
+			maxPrice := reference.Price
+			ingredientID := reference.Ingredients[0].IngredientID
+			nameContains := strings.ToUpper(reference.Name[1:4])
+
+			queries := map[string]struct {
+				query   CoffeeQuery
+				matches func(entities.Coffee) bool
+			}{
+				"max_price": {
+					CoffeeQuery{MaxPrice: &maxPrice},
+					func(c entities.Coffee) bool { return c.Price <= maxPrice },
+				},
+				"ingredient": {
+					CoffeeQuery{IngredientID: ingredientID},
+					func(c entities.Coffee) bool {
+						for _, ci := range c.Ingredients {
+							if ci.IngredientID == ingredientID {
+								return true
+							}
+						}
+						return false
+					},
+				},
+				"name_contains": {
+					CoffeeQuery{NameContains: nameContains},
+					func(c entities.Coffee) bool {
+						return strings.Contains(strings.ToLower(c.Name), strings.ToLower(nameContains))
+					},
+				},
+			}
+
+			for filter, tc := range queries {
+				page, err := repository.Find(context.Background(), tc.query)
+				require.NoError(t, err)
+
+				expected := []int{}
+				for _, c := range all.Coffees {
+					if tc.matches(c) {
+						expected = append(expected, c.ID)
+					}
+				}
+
+				assert.Equal(t, expected, coffeeIDs(page.Coffees), filter)
+				assert.Equal(t, len(expected), page.Total, filter)
+				assert.Contains(t, coffeeIDs(page.Coffees), reference.ID, filter)
+			}
+		})
+	}
+}
+
+func TestFindPaginatesInSortOrder(t *testing.T) {
+	sorts := [][]Sort{
+		nil,
+		{{Field: SortByPrice}, {Field: SortByName, Descending: true}},
+		{{Field: SortByPrice, Descending: true}},
+		{{Field: SortByName}},
+	}
+
+	for name, repository := range setupRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, s := range sorts {
+				all, err := repository.Find(context.Background(), CoffeeQuery{Sort: s})
+				require.NoError(t, err)
+
+				keys := CoffeeQuery{Sort: s}.sortKeys()
+				for n := 1; n < len(all.Coffees); n++ {
+					assert.True(t, compareCoffees(cursorFor(all.Coffees[n-1]), cursorFor(all.Coffees[n]), keys) < 0, "%v is not sorted", s)
+				}
+
+				paged := []int{}
+				query := CoffeeQuery{Sort: s, Limit: 2}
+
+				for {
+					page, err := repository.Find(context.Background(), query)
+					require.NoError(t, err)
+					require.True(t, len(page.Coffees) <= 2)
+					assert.Equal(t, len(all.Coffees), page.Total)
+
+					paged = append(paged, coffeeIDs(page.Coffees)...)
+
+					if page.NextCursor == "" {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+
+				assert.Equal(t, coffeeIDs(all.Coffees), paged, "%v", s)
+			}
+		})
+	}
+}
+
+func ingredientIDs(ingredients entities.Ingredients) []int {
+	ids := make([]int, len(ingredients))
+	for n, i := range ingredients {
+		ids[n] = i.ID
+	}
+	return ids
+}
+
+func coffeeIDs(coffees entities.Coffees) []int {
+	ids := make([]int, len(coffees))
+	for n, c := range coffees {
+		ids[n] = c.ID
+	}
+	return ids
+}
@@ -0,0 +1,344 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// ErrNotEmpty is returned when seeding a database that already has coffees
+var ErrNotEmpty = errors.New("database already has coffees")
+
+// Seed is the menu loaded into empty databases: the ingredient catalog, the
+// coffees, and the recipes listing the ingredients of each coffee.
+type Seed struct {
+	Ingredients []SeedIngredient `json:"ingredients" yaml:"ingredients"`
+	Coffees     []SeedCoffee     `json:"coffees" yaml:"coffees"`
+	Recipes     []SeedRecipe     `json:"recipes" yaml:"recipes"`
+}
+
+// SeedIngredient is an ingredient of the Seed
+type SeedIngredient struct {
+	ID   int    `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
+}
+
+// SeedCoffee is a coffee of the Seed
+type SeedCoffee struct {
+	ID          int     `json:"id" yaml:"id"`
+	Name        string  `json:"name" yaml:"name"`
+	Teaser      string  `json:"teaser" yaml:"teaser"`
+	Description string  `json:"description" yaml:"description"`
+	Price       float64 `json:"price" yaml:"price"`
+	Image       string  `json:"image" yaml:"image"`
+}
+
+// SeedRecipe is the quantity of an ingredient used by a coffee of the Seed
+type SeedRecipe struct {
+	CoffeeID     int    `json:"coffee_id" yaml:"coffee_id"`
+	IngredientID int    `json:"ingredient_id" yaml:"ingredient_id"`
+	Quantity     int    `json:"quantity" yaml:"quantity"`
+	Unit         string `json:"unit" yaml:"unit"`
+}
+
+// LoadSeed returns the seed of the configuration, read from cfg.SeedFile, or
+// the default menu when no file is configured.
+func LoadSeed(cfg *config.Config) (*Seed, error) {
+	if cfg.SeedFile == "" {
+		return DefaultSeed(), nil
+	}
+
+	cfg.Logger.Debug("Loading seed", "path", cfg.SeedFile)
+	return ReadSeedFile(cfg.SeedFile)
+}
+
+// ReadSeedFile reads and validates a JSON or YAML seed file, the format is
+// chosen by the extension. Unknown fields are rejected so that typos do not go
+// unnoticed.
+func ReadSeedFile(path string) (*Seed, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := &Seed{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(seed)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(seed)
+	default:
+		return nil, fmt.Errorf("seed file %s must have a .json, .yaml or .yml extension", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse seed file %s: %w", path, err)
+	}
+
+	if err := seed.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid seed file %s: %w", path, err)
+	}
+
+	return seed, nil
+}
+
+// Validate checks that the ids are unique and that every recipe references a
+// coffee and an ingredient of the seed. It returns a *entities.ValidationError
+// describing the first invalid field.
+func (s *Seed) Validate() error {
+	ingredients := map[int]bool{}
+	for n, i := range s.Ingredients {
+		field := fmt.Sprintf("ingredients[%d]", n)
+
+		if i.ID <= 0 {
+			return &entities.ValidationError{Field: field + ".id", Reason: "must be greater than 0"}
+		}
+		if ingredients[i.ID] {
+			return &entities.ValidationError{Field: field + ".id", Reason: fmt.Sprintf("duplicates ingredient %d", i.ID)}
+		}
+		if strings.TrimSpace(i.Name) == "" {
+			return &entities.ValidationError{Field: field + ".name", Reason: "is required"}
+		}
+
+		ingredients[i.ID] = true
+	}
+
+	coffees := map[int]bool{}
+	for n, c := range s.Coffees {
+		field := fmt.Sprintf("coffees[%d]", n)
+
+		if c.ID <= 0 {
+			return &entities.ValidationError{Field: field + ".id", Reason: "must be greater than 0"}
+		}
+		if coffees[c.ID] {
+			return &entities.ValidationError{Field: field + ".id", Reason: fmt.Sprintf("duplicates coffee %d", c.ID)}
+		}
+		if strings.TrimSpace(c.Name) == "" {
+			return &entities.ValidationError{Field: field + ".name", Reason: "is required"}
+		}
+		if c.Price < 0 {
+			return &entities.ValidationError{Field: field + ".price", Reason: "must not be negative"}
+		}
+
+		coffees[c.ID] = true
+	}
+
+	recipes := map[[2]int]bool{}
+	for n, r := range s.Recipes {
+		field := fmt.Sprintf("recipes[%d]", n)
+
+		if !coffees[r.CoffeeID] {
+			return &entities.ValidationError{Field: field + ".coffee_id", Reason: fmt.Sprintf("references unknown coffee %d", r.CoffeeID)}
+		}
+		if !ingredients[r.IngredientID] {
+			return &entities.ValidationError{Field: field + ".ingredient_id", Reason: fmt.Sprintf("references unknown ingredient %d", r.IngredientID)}
+		}
+		if recipes[[2]int{r.CoffeeID, r.IngredientID}] {
+			return &entities.ValidationError{Field: field, Reason: fmt.Sprintf("duplicates ingredient %d of coffee %d", r.IngredientID, r.CoffeeID)}
+		}
+		if r.Quantity < 0 {
+			return &entities.ValidationError{Field: field + ".quantity", Reason: "must not be negative"}
+		}
+
+		recipes[[2]int{r.CoffeeID, r.IngredientID}] = true
+	}
+
+	return nil
+}
+
+// SeedDatabase loads the seed into the Postgres or SQLite database of the
+// configuration. A database with coffees is only seeded when replace is set,
+// replacing its whole menu, otherwise ErrNotEmpty is returned.
+func SeedDatabase(ctx context.Context, cfg *config.Config, seed *Seed, replace bool) error {
+	var repository *sqlRepository
+
+	switch cfg.DBBackend {
+	case config.Postgres:
+		c := &connector{
+			dial: func() (Repository, error) {
+				return dialPostgres(cfg)
+			},
+			backoff: newBackoff(cfg.DBConnect),
+			clock:   realClock{},
+			logger:  cfg.Logger,
+		}
+
+		connected, err := c.connect(ctx, cfg.DBConnect.MaxWait)
+		if err != nil {
+			return err
+		}
+		repository = connected.(*PostgresRepository).sqlRepository
+	case config.SQLite:
+		opened, err := openSQLite(cfg)
+		if err != nil {
+			return err
+		}
+		repository = opened.sqlRepository
+	default:
+		return fmt.Errorf("seeding is only supported by the %s and %s backends, %s loads the seed on start", config.Postgres, config.SQLite, cfg.DBBackend)
+	}
+	defer repository.Close()
+
+	return repository.seed(ctx, seed, replace)
+}
+
+// seed loads the seed in a single transaction. ErrNotEmpty is returned when
+// the database has coffees, unless replace deletes the whole menu first.
+func (r *sqlRepository) seed(ctx context.Context, seed *Seed, replace bool) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var coffees int
+
+	if err = tx.GetContext(ctx, &coffees, "SELECT count(*) FROM coffee"); err != nil {
+		return err
+	}
+
+	if coffees > 0 && !replace {
+		return ErrNotEmpty
+	}
+
+	if replace {
+		for _, table := range []TableNameKey{CoffeeIngredient, Coffee, Ingredient} {
+			if _, err = tx.ExecContext(ctx, "DELETE FROM "+table.String()); err != nil {
+				return err
+			}
+		}
+	}
+
+	timestamp := currentTime()
+
+	for _, i := range seed.ingredients(timestamp) {
+		_, err = tx.NamedExecContext(ctx, `INSERT INTO ingredient (id, name, created_at, updated_at)
+			VALUES (:id, :name, :created_at, :updated_at)`, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, c := range seed.coffees(timestamp) {
+		_, err = tx.NamedExecContext(ctx, `INSERT INTO coffee (id, name, teaser, description, price, image, created_at, updated_at)
+			VALUES (:id, :name, :teaser, :description, :price, :image, :created_at, :updated_at)`, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ci := range seed.coffeeIngredients(timestamp) {
+		_, err = tx.NamedExecContext(ctx, `INSERT INTO coffee_ingredient (id, coffee_id, ingredient_id, quantity, unit, created_at, updated_at)
+			VALUES (:id, :coffee_id, :ingredient_id, :quantity, :unit, :created_at, :updated_at)`, ci)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.dialect.resetSequence != "" {
+		for _, table := range []TableNameKey{Ingredient, Coffee, CoffeeIngredient} {
+			if _, err = tx.ExecContext(ctx, fmt.Sprintf(r.dialect.resetSequence, table)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ingredients returns the rows of the ingredient table
+func (s *Seed) ingredients(timestamp time.Time) []*entities.Ingredient {
+	rows := make([]*entities.Ingredient, len(s.Ingredients))
+	for n, i := range s.Ingredients {
+		rows[n] = &entities.Ingredient{ID: i.ID, Name: i.Name, CreatedAt: timestamp, UpdatedAt: timestamp}
+	}
+
+	return rows
+}
+
+// coffees returns the rows of the coffee table
+func (s *Seed) coffees(timestamp time.Time) []*entities.Coffee {
+	rows := make([]*entities.Coffee, len(s.Coffees))
+	for n, c := range s.Coffees {
+		rows[n] = &entities.Coffee{
+			ID:          c.ID,
+			Name:        c.Name,
+			Teaser:      c.Teaser,
+			Description: c.Description,
+			Price:       c.Price,
+			Image:       c.Image,
+			CreatedAt:   timestamp,
+			UpdatedAt:   timestamp,
+		}
+	}
+
+	return rows
+}
+
+// coffeeIngredients returns the rows of the coffee_ingredient table, numbered
+// in the order of the recipes
+func (s *Seed) coffeeIngredients(timestamp time.Time) []*entities.CoffeeIngredients {
+	rows := make([]*entities.CoffeeIngredients, len(s.Recipes))
+	for n, r := range s.Recipes {
+		rows[n] = &entities.CoffeeIngredients{
+			ID:           n + 1,
+			CoffeeID:     r.CoffeeID,
+			IngredientID: r.IngredientID,
+			Quantity:     r.Quantity,
+			Unit:         r.Unit,
+			CreatedAt:    timestamp,
+			UpdatedAt:    timestamp,
+		}
+	}
+
+	return rows
+}
+
+// DefaultSeed returns the menu loaded when no seed file is configured
+func DefaultSeed() *Seed {
+	return &Seed{
+		Ingredients: []SeedIngredient{
+			{ID: 1, Name: "Espresso"},
+			{ID: 2, Name: "Semi Skimmed Milk"},
+			{ID: 3, Name: "Hot Water"},
+			{ID: 4, Name: "Pumpkin Spice"},
+			{ID: 5, Name: "Steamed Milk"},
+		},
+		Coffees: []SeedCoffee{
+			{ID: 1, Name: "Packer Spiced Latte", Teaser: "Packed with goodness to spice up your images", Price: 350, Image: "/packer.png"},
+			{ID: 2, Name: "Vaulatte", Teaser: "Nothing gives you a safe and secure feeling like a Vaulatte", Price: 200, Image: "/vault.png"},
+			{ID: 3, Name: "Nomadicano", Teaser: "Drink one today and you will want to schedule another", Price: 150, Image: "/nomad.png"},
+			{ID: 4, Name: "Terraspresso", Teaser: "Nothing kickstarts your day like a provision of Terraspresso", Price: 150, Image: "/terraform.png"},
+			{ID: 5, Name: "Vagrante espresso", Teaser: "Stdin is not a tty", Price: 200, Image: "/vagrant.png"},
+			{ID: 6, Name: "Connectaccino", Teaser: "Discover the wonders of our meshy service", Price: 250, Image: "/consul.png"},
+		},
+		Recipes: []SeedRecipe{
+			{CoffeeID: 1, IngredientID: 1, Quantity: 40, Unit: "ml"},
+			{CoffeeID: 1, IngredientID: 2, Quantity: 300, Unit: "ml"},
+			{CoffeeID: 1, IngredientID: 4, Quantity: 5, Unit: "g"},
+			{CoffeeID: 2, IngredientID: 1, Quantity: 40, Unit: "ml"},
+			{CoffeeID: 2, IngredientID: 2, Quantity: 300, Unit: "ml"},
+			{CoffeeID: 3, IngredientID: 1, Quantity: 20, Unit: "ml"},
+			{CoffeeID: 3, IngredientID: 3, Quantity: 100, Unit: "ml"},
+			{CoffeeID: 4, IngredientID: 1, Quantity: 40, Unit: "ml"},
+			{CoffeeID: 5, IngredientID: 1, Quantity: 40, Unit: "ml"},
+			{CoffeeID: 6, IngredientID: 1, Quantity: 40, Unit: "ml"},
+			{CoffeeID: 6, IngredientID: 5, Quantity: 300, Unit: "ml"},
+		},
+	}
+}
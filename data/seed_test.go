@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+const yamlSeed = `
+ingredients:
+  - id: 1
+    name: Espresso
+  - id: 2
+    name: Oat Milk
+coffees:
+  - id: 10
+    name: Boundary Brew
+    teaser: Identity-based coffee
+    price: 275
+    image: /boundary.png
+recipes:
+  - coffee_id: 10
+    ingredient_id: 1
+    quantity: 40
+    unit: ml
+  - coffee_id: 10
+    ingredient_id: 2
+    quantity: 200
+    unit: ml
+`
+
+const jsonSeed = `{
+  "ingredients": [{"id": 1, "name": "Espresso"}, {"id": 2, "name": "Oat Milk"}],
+  "coffees": [{"id": 10, "name": "Boundary Brew", "teaser": "Identity-based coffee", "price": 275, "image": "/boundary.png"}],
+  "recipes": [
+    {"coffee_id": 10, "ingredient_id": 1, "quantity": 40, "unit": "ml"},
+    {"coffee_id": 10, "ingredient_id": 2, "quantity": 200, "unit": "ml"}
+  ]
+}`
+
+// writeSeedFile writes the content to a seed file named name
+func writeSeedFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	return path
+}
+
+func TestDefaultSeedIsValid(t *testing.T) {
+	assert.NoError(t, DefaultSeed().Validate())
+}
+
+func TestReadSeedFileParsesYAMLAndJSON(t *testing.T) {
+	expected := &Seed{
+		Ingredients: []SeedIngredient{{ID: 1, Name: "Espresso"}, {ID: 2, Name: "Oat Milk"}},
+		Coffees:     []SeedCoffee{{ID: 10, Name: "Boundary Brew", Teaser: "Identity-based coffee", Price: 275, Image: "/boundary.png"}},
+		Recipes: []SeedRecipe{
+			{CoffeeID: 10, IngredientID: 1, Quantity: 40, Unit: "ml"},
+			{CoffeeID: 10, IngredientID: 2, Quantity: 200, Unit: "ml"},
+		},
+	}
+
+	for name, content := range map[string]string{"menu.yaml": yamlSeed, "menu.yml": yamlSeed, "menu.json": jsonSeed} {
+		t.Run(name, func(t *testing.T) {
+			seed, err := ReadSeedFile(writeSeedFile(t, name, content))
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, seed)
+		})
+	}
+}
+
+func TestReadSeedFileRejectsInvalidFiles(t *testing.T) {
+	cases := map[string]string{
+		"menu.yaml": "coffees:\n  - id: 1\n    nmae: Typo\n",
+		"menu.json": `{"coffees": [{"id": 1, "nmae": "Typo"}]}`,
+		"menu.toml": `coffees = []`,
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadSeedFile(writeSeedFile(t, name, content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSeedValidateChecksReferentialIntegrity(t *testing.T) {
+	cases := map[string]struct {
+		change func(s *Seed)
+		field  string
+	}{
+		"duplicate ingredient": {func(s *Seed) { s.Ingredients[1].ID = 1 }, "ingredients[1].id"},
+		"unnamed ingredient":   {func(s *Seed) { s.Ingredients[0].Name = " " }, "ingredients[0].name"},
+		"duplicate coffee":     {func(s *Seed) { s.Coffees[1].ID = 1 }, "coffees[1].id"},
+		"negative price":       {func(s *Seed) { s.Coffees[0].Price = -1 }, "coffees[0].price"},
+		"unknown coffee":       {func(s *Seed) { s.Recipes[0].CoffeeID = 99 }, "recipes[0].coffee_id"},
+		"unknown ingredient":   {func(s *Seed) { s.Recipes[0].IngredientID = 99 }, "recipes[0].ingredient_id"},
+		"duplicate recipe":     {func(s *Seed) { s.Recipes[1].IngredientID = 1 }, "recipes[1]"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			seed := DefaultSeed()
+			tc.change(seed)
+
+			err := seed.Validate()
+
+			var verr *entities.ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, tc.field, verr.Field)
+		})
+	}
+}
+
+func TestInMemoryRepositoryLoadsSeedFile(t *testing.T) {
+	repository, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger(), SeedFile: writeSeedFile(t, "menu.yaml", yamlSeed)})
+	require.NoError(t, err)
+
+	page, err := repository.Find(context.Background(), CoffeeQuery{})
+	require.NoError(t, err)
+
+	require.Len(t, page.Coffees, 1)
+	assert.Equal(t, "Boundary Brew", page.Coffees[0].Name)
+	assert.Len(t, page.Coffees[0].Ingredients, 2)
+}
+
+func TestInMemoryRepositoryRejectsInvalidSeedFile(t *testing.T) {
+	_, err := NewInMemoryDB(&config.Config{Logger: hclog.NewNullLogger(), SeedFile: filepath.Join(t.TempDir(), "missing.yaml")})
+
+	assert.Error(t, err)
+}
+
+func TestSeedDatabaseReplacesSQLiteMenu(t *testing.T) {
+	cfg := &config.Config{
+		Logger:     hclog.NewNullLogger(),
+		DBBackend:  config.SQLite,
+		SQLitePath: filepath.Join(t.TempDir(), "coffee-service.db"),
+	}
+	seed, err := ReadSeedFile(writeSeedFile(t, "menu.yaml", yamlSeed))
+	require.NoError(t, err)
+
+	require.NoError(t, SeedDatabase(context.Background(), cfg, DefaultSeed(), false))
+	assert.Equal(t, ErrNotEmpty, SeedDatabase(context.Background(), cfg, seed, false))
+	require.NoError(t, SeedDatabase(context.Background(), cfg, seed, true))
+
+	repository, err := NewSQLite(cfg)
+	require.NoError(t, err)
+	defer repository.Close()
+
+	page, err := repository.Find(context.Background(), CoffeeQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Coffees, 1)
+	assert.Equal(t, "Boundary Brew", page.Coffees[0].Name)
+
+	created, err := repository.CreateCoffee(context.Background(), entities.Coffee{Name: "Vaulatte", Price: 200})
+	require.NoError(t, err)
+	assert.Greater(t, created.ID, 10)
+}
+
+func TestSeedResetsPostgresSequences(t *testing.T) {
+	repository, mock, _ := setupMockPostgres(t)
+	seed := &Seed{Ingredients: []SeedIngredient{{ID: 1, Name: "Espresso"}}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM coffee`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`INSERT INTO ingredient`).WillReturnResult(sqlmock.NewResult(1, 1))
+	for _, table := range []string{"ingredient", "coffee", "coffee_ingredient"} {
+		mock.ExpectExec(`SELECT setval\(pg_get_serial_sequence\('` + table + `', 'id'\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectCommit()
+
+	require.NoError(t, repository.seed(context.Background(), seed, false))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,8 @@
+//go:build !cgo
+// +build !cgo
+
+package data
+
+// sqliteSupported reports whether the binary can open SQLite databases, the
+// driver requires cgo
+const sqliteSupported = false
@@ -0,0 +1,19 @@
+//go:build !cgo
+// +build !cgo
+
+package data
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+func TestNewRepositoryRefusesSQLiteWithoutCgo(t *testing.T) {
+	_, err := NewRepository(&config.Config{DBBackend: config.SQLite, Logger: hclog.NewNullLogger(), SQLitePath: t.TempDir() + "/coffee-service.db"})
+
+	assert.ErrorIs(t, err, ErrSQLiteUnsupported)
+}
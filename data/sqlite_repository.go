@@ -0,0 +1,139 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	// Registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// sqliteSchema creates the tables used by the SQLiteRepository if they do not
+// already exist.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS ingredient (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	deleted_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS coffee (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	name        TEXT NOT NULL,
+	teaser      TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	price       REAL NOT NULL,
+	image       TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMP NOT NULL,
+	updated_at  TIMESTAMP NOT NULL,
+	deleted_at  TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS coffee_ingredient (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	coffee_id     INTEGER NOT NULL REFERENCES coffee (id),
+	ingredient_id INTEGER NOT NULL REFERENCES ingredient (id),
+	quantity      INTEGER NOT NULL DEFAULT 0,
+	unit          TEXT NOT NULL DEFAULT '',
+	created_at    TIMESTAMP NOT NULL,
+	updated_at    TIMESTAMP NOT NULL,
+	deleted_at    TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS coffee_ingredient_coffee_id ON coffee_ingredient (coffee_id);
+CREATE INDEX IF NOT EXISTS coffee_ingredient_ingredient_id ON coffee_ingredient (ingredient_id);
+`
+
+// sqliteDialect is the dialect of SQLite
+var sqliteDialect = dialect{
+	like:       "LIKE",
+	likeEscape: ` ESCAPE '\'`,
+	bytewise:   "BINARY",
+	anyOf: func(column string, n int, ids []int64) (string, interface{}) {
+		// ids always marshal, the error can be ignored
+		list, _ := json.Marshal(ids)
+		return fmt.Sprintf("%s IN (SELECT value FROM json_each($%d))", column, n), string(list)
+	},
+}
+
+// ErrSQLiteUnsupported is returned for the sqlite backend by a binary built
+// with CGO_ENABLED=0, the SQLite driver requires cgo.
+var ErrSQLiteUnsupported = errors.New("the sqlite backend requires a binary built with cgo, set DB_BACKEND to postgres or memory, or build with CGO_ENABLED=1")
+
+// SQLiteRepository is a file backed SQLite implementation of the Repository
+// interface. It allows the service to persist data without running Postgres.
+type SQLiteRepository struct {
+	*sqlRepository
+}
+
+// NewSQLite is the SQLiteRepository factory method. It opens the database file
+// at cfg.SQLitePath, creating the file and the schema if they do not exist,
+// and loads the seed of the configuration when the database is empty.
+func NewSQLite(cfg *config.Config) (Repository, error) {
+	seed, err := LoadSeed(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	repository, err := openSQLite(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repository.seed(context.Background(), seed, false); err != nil && err != ErrNotEmpty {
+		repository.Close()
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+// openSQLite opens the database file at cfg.SQLitePath and creates the schema.
+// ErrSQLiteUnsupported is returned by a binary built without cgo.
+func openSQLite(cfg *config.Config) (*SQLiteRepository, error) {
+	if !sqliteSupported {
+		return nil, ErrSQLiteUnsupported
+	}
+
+	cfg.Logger.Debug("Opening SQLite database", "path", cfg.SQLitePath)
+
+	db, err := sql.Open("sqlite3", sqliteDSN(cfg.SQLitePath))
+	if err != nil {
+		return nil, err
+	}
+
+	repository, err := newSQLite(sqlx.NewDb(db, "sqlite3"))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+// newSQLite creates the schema of the database behind the connection
+func newSQLite(db *sqlx.DB) (*SQLiteRepository, error) {
+	// SQLite allows a single writer, sharing one connection serializes the
+	// transactions instead of failing them with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, err
+	}
+
+	return &SQLiteRepository{&sqlRepository{db: db, dialect: sqliteDialect}}, nil
+}
+
+// sqliteDSN returns the data source name for the database file at path
+func sqliteDSN(path string) string {
+	return fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path)
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestSQLitePersistsDataBetweenConnections(t *testing.T) {
+	cfg := &config.Config{
+		Logger:     hclog.NewNullLogger(),
+		SQLitePath: filepath.Join(t.TempDir(), "coffee-service.db"),
+	}
+
+	repository, err := NewSQLite(cfg)
+	require.NoError(t, err)
+
+	seeded, err := repository.Find(context.Background(), CoffeeQuery{})
+	require.NoError(t, err)
+	require.NotEmpty(t, seeded.Coffees)
+
+	created, err := repository.CreateCoffee(context.Background(), entities.Coffee{
+		Name:        "Boundary Brew",
+		Price:       275,
+		Ingredients: []entities.CoffeeIngredients{{IngredientID: 1, Quantity: 40, Unit: "ml"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, repository.Close())
+
+	reopened, err := NewSQLite(cfg)
+	require.NoError(t, err)
+
+	page, err := reopened.Find(context.Background(), CoffeeQuery{})
+	require.NoError(t, err)
+	assert.Len(t, page.Coffees, len(seeded.Coffees)+1, "the menu should not be seeded twice")
+
+	found, err := reopened.FindByID(context.Background(), created.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, created, found)
+}
+
+func TestSQLiteCloseReleasesDatabase(t *testing.T) {
+	repository, err := NewSQLite(&config.Config{
+		Logger:     hclog.NewNullLogger(),
+		SQLitePath: filepath.Join(t.TempDir(), "coffee-service.db"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repository.Ping(context.Background()))
+	require.NoError(t, repository.Close())
+	assert.Error(t, repository.Ping(context.Background()))
+}
@@ -8,8 +8,73 @@ Feature: Coffees Functionality
     Then a list of products should be returned
     And the response status should be "OK"
 
+  Scenario: Get a page of products
+    Given the server is running
+    When I make a "GET" request to "/coffees?limit=2&sort=-price"
+    Then 2 products should be returned
+    And the response header "X-Total-Count" should be "6"
+    And the response status should be "OK"
+
+  Scenario: Filter products
+    Given the server is running
+    When I make a "GET" request to "/coffees?max_price=150&ingredient=3"
+    Then 1 products should be returned
+    And the response header "X-Total-Count" should be "1"
+
   Scenario: Get a product's ingredients
     Given the server is running
     When I make a "GET" request to "/coffees/{id:[0-9]+}/ingredients" where "id" is "1"
     Then a list of the product's ingredients should be returned
-    And the response status should be "OK"
\ No newline at end of file
+    And the ingredients should be:
+      | name              | quantity | unit |
+      | Espresso          | 40       | ml   |
+      | Semi Skimmed Milk | 300      | ml   |
+      | Pumpkin Spice     | 5        | g    |
+    And the response status should be "OK"
+
+  Scenario: Get the ingredients of a product that does not exist
+    Given the server is running
+    When I make a "GET" request to "/coffees/{id:[0-9]+}/ingredients" where "id" is "99"
+    Then the response status should be "Not Found"
+
+  Scenario: Create a product
+    Given the server is running
+    When I make a "POST" request to "/coffees" with the following request body:
+      """
+      {
+        "name": "Boundary Brew",
+        "teaser": "Secure access to your morning",
+        "price": 300,
+        "ingredients": [
+          { "ingredient_id": 1, "quantity": 40, "unit": "ml" },
+          { "ingredient_id": 3, "quantity": 100, "unit": "ml" }
+        ]
+      }
+      """
+    Then the response status should be "Created"
+
+  Scenario: Create a product with an unknown ingredient
+    Given the server is running
+    When I make a "POST" request to "/coffees" with the following request body:
+      """
+      {
+        "name": "Boundary Brew",
+        "price": 300,
+        "ingredients": [
+          { "ingredient_id": 99, "quantity": 40, "unit": "ml" }
+        ]
+      }
+      """
+    Then the response status should be "Bad Request"
+
+  Scenario: Delete a product
+    Given the server is running
+    When I make a "DELETE" request to "/coffees/{id:[0-9]+}" where "id" is "1"
+    Then the response status should be "No Content"
+
+  Scenario: Revalidate the products with their ETag
+    Given the server is running
+    When I make a "GET" request to "/coffees"
+    And I make a "GET" request to "/coffees" with the header "If-None-Match" set to the response header "ETag"
+    Then the response status should be "Not Modified"
+    And the response header "Cache-Control" should be "no-cache"
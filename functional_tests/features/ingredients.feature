@@ -0,0 +1,21 @@
+Feature: Ingredients Functionality
+  In order to maintain the ingredient catalog independently of coffees
+  Test the system
+
+  Scenario: Get ingredients
+    Given the server is running
+    When I make a "GET" request to "/ingredients"
+    Then the response status should be "OK"
+
+  Scenario: Create an ingredient
+    Given the server is running
+    When I make a "POST" request to "/ingredients" with the following request body:
+      """
+      { "name": "Oat Milk" }
+      """
+    Then the response status should be "Created"
+
+  Scenario: Delete an ingredient used by a product
+    Given the server is running
+    When I make a "DELETE" request to "/ingredients/{id:[0-9]+}" where "id" is "1"
+    Then the response status should be "Conflict"
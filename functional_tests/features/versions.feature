@@ -0,0 +1,57 @@
+Feature: API Versions
+  In order to migrate clients between versions of the api
+  Every version is served by the same service
+
+  Scenario: Get products from a version prefix
+    Given the server is running
+    When I make a "GET" request to "/v3/coffees"
+    Then a list of products should be returned
+    And the response header "API-Version" should be "v3"
+    And the response status should be "OK"
+
+  Scenario: Get products from the default version
+    Given the server is running
+    When I make a "GET" request to "/coffees"
+    Then the response header "API-Version" should be "v1"
+
+  Scenario: Select the version with the API-Version header
+    Given the server is running
+    When I make a "GET" request to "/coffees" with the header "API-Version" set to "v2"
+    Then the response header "API-Version" should be "v2"
+    And the response status should be "OK"
+
+  Scenario: Select the version with the Accept header
+    Given the server is running
+    When I make a "GET" request to "/coffees" with the header "Accept" set to "application/vnd.coffee.v3+json"
+    Then the response header "API-Version" should be "v3"
+    And the response status should be "OK"
+
+  Scenario: Request an unknown version
+    Given the server is running
+    When I make a "GET" request to "/coffees" with the header "Accept" set to "application/vnd.coffee.v9+json"
+    Then the response status should be "Not Acceptable"
+
+  Scenario: Deprecated versions announce their sunset
+    Given the server is running
+    When I make a "GET" request to "/v1/coffees"
+    Then the response header "Deprecation" should be "true"
+    And the response header "Sunset" should be "Wed, 30 Jun 2021 00:00:00 GMT"
+
+  Scenario: v1 products reference their ingredients by id
+    Given the server is running
+    When I make a "GET" request to "/v1/coffees"
+    Then the first product's "ingredients.0.ingredient_id" should be "1"
+    And the first product's "price" should be "350"
+
+  Scenario: v2 products embed their ingredients
+    Given the server is running
+    When I make a "GET" request to "/v2/coffees"
+    Then the first product's "ingredients.0.name" should be "Espresso"
+    And the first product's "ingredients.0.quantity" should be "40"
+
+  Scenario: v3 products carry the currency of their price and their availability
+    Given the server is running
+    When I make a "GET" request to "/v3/coffees"
+    Then the first product's "price.amount" should be "350"
+    And the first product's "price.currency" should be "USD"
+    And the first product's "available" should be "true"
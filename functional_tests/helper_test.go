@@ -6,29 +6,54 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cucumber/messages-go/v10"
 	"github.com/gorilla/mux"
+	"github.com/hashicorp-demoapp/coffee-service/config"
 	"github.com/hashicorp-demoapp/coffee-service/data"
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
-	v1 "github.com/hashicorp-demoapp/coffee-service/service/v1"
+	"github.com/hashicorp-demoapp/coffee-service/service"
 	"github.com/hashicorp/go-hclog"
 )
 
-func (api *V1APIFeature) initService() {
-	repo := data.MockRepository{}
-	repo.On("Find").Return(entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, nil)
-	api.svc = v1.NewCoffeeService(&repo, hclog.Default())
-}
+func (api *V1APIFeature) theServerIsRunning() error {
+	cfg := &config.Config{
+		Logger:  hclog.Default(),
+		Version: config.V1,
+		Deprecations: map[config.VersionKey]config.Deprecation{
+			config.V1: {Sunset: time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	repo, err := data.NewInMemoryDB(cfg)
+	if err != nil {
+		return err
+	}
 
-func (api *V1APIFeature) initHandlers() error {
-	mockRepo := &data.MockRepository{}
-	mockRepo.On("Find").Return(entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, nil)
+	versions, err := service.NewCoffeeVersions(cfg, repo)
+	if err != nil {
+		return err
+	}
 
-	logger := hclog.Default()
+	coffees, err := service.NewVersionedCoffee(cfg, versions)
+	if err != nil {
+		return err
+	}
 
-	api.svc = v1.NewCoffeeService(mockRepo, logger)
+	ingredients := service.NewIngredient(repo, hclog.Default())
+
+	api.router = mux.NewRouter()
+	for _, version := range config.Versions {
+		versionRouter := api.router.PathPrefix("/" + version.String()).Subrouter()
+		versionRouter.Use(service.VersionHeaders(cfg, version))
+		service.RegisterCoffeeRoutes(versionRouter, versions[version], "no-cache")
+	}
+	service.RegisterCoffeeRoutes(api.router, coffees, "no-cache")
+	service.RegisterIngredientRoutes(api.router, ingredients, "no-cache")
 
 	return nil
 }
@@ -37,27 +62,36 @@ func (api *V1APIFeature) iMakeARequestTo(method, endpoint string) error {
 	api.rw = httptest.NewRecorder()
 	api.r = httptest.NewRequest(method, endpoint, nil)
 
-	err := api.initHandlers()
-	if err != nil {
-		return err
-	}
+	api.router.ServeHTTP(api.rw, api.r)
 
 	return nil
 }
 
-func (api *V1APIFeature) iMakeARequestToWhereIs(method, endpoint string, attribute, value string) error {
+func (api *V1APIFeature) iMakeARequestToWithTheHeaderSetTo(method, endpoint, header, value string) error {
 	api.rw = httptest.NewRecorder()
 	api.r = httptest.NewRequest(method, endpoint, nil)
+	api.r.Header.Set(header, value)
 
-	vars := map[string]string{attribute: value}
-	api.r = mux.SetURLVars(api.r, vars)
+	api.router.ServeHTTP(api.rw, api.r)
 
-	err := api.initHandlers()
-	if err != nil {
-		return err
+	return nil
+}
+
+func (api *V1APIFeature) iMakeARequestToWithTheHeaderSetToTheResponseHeader(method, endpoint, header, responseHeader string) error {
+	// The header of the previous response, e.g. its ETag
+	value := api.rw.Header().Get(responseHeader)
+	if value == "" {
+		return fmt.Errorf("expected the previous response to have the header %s", responseHeader)
 	}
 
-	return nil
+	return api.iMakeARequestToWithTheHeaderSetTo(method, endpoint, header, value)
+}
+
+func (api *V1APIFeature) iMakeARequestToWhereIs(method, endpoint string, attribute, value string) error {
+	// Substitute the route variable, e.g. {id:[0-9]+}, with the value
+	variable := regexp.MustCompile(`\{` + regexp.QuoteMeta(attribute) + `(:[^}]*)?\}`)
+
+	return api.iMakeARequestTo(method, variable.ReplaceAllString(endpoint, value))
 }
 
 func (api *V1APIFeature) iMakeARequestToWithTheFollowingRequestBody(method, endpoint string, body *messages.PickleStepArgument_PickleDocString) error {
@@ -67,21 +101,24 @@ func (api *V1APIFeature) iMakeARequestToWithTheFollowingRequestBody(method, endp
 	rb := strings.NewReader(body.Content)
 	api.r.Body = ioutil.NopCloser(rb)
 
-	err := api.initHandlers()
-	if err != nil {
-		return err
-	}
+	api.router.ServeHTTP(api.rw, api.r)
 
 	return nil
 }
 
 func (api *V1APIFeature) aListOfProductsShouldBeReturned() error {
-	bd := entities.Coffees{}
+	// The representation of a product depends on the api version
+	bd := []map[string]interface{}{}
 
 	err := json.Unmarshal(api.rw.Body.Bytes(), &bd)
 	if err != nil {
 		return err
 	}
+
+	if len(bd) == 0 {
+		return fmt.Errorf("expected a list of products, got an empty list")
+	}
+
 	return nil
 }
 
@@ -91,17 +128,120 @@ func (api *V1APIFeature) thatProductsIngredientsShouldBeReturned() error {
 	if err != nil {
 		return err
 	}
+
+	if len(bd) == 0 {
+		return fmt.Errorf("expected a list of ingredients, got an empty list")
+	}
+
 	return nil
 }
 
-func (api *V1APIFeature) theResponseStatusShouldBe(statusCode string) error {
-	switch statusCode {
-	case "OK":
-		if api.rw.Code != http.StatusOK {
-			return fmt.Errorf("expected status code does not match actual, %v vs. %v", http.StatusOK, api.rw.Code)
+func (api *V1APIFeature) theIngredientsShouldBe(table *messages.PickleStepArgument_PickleTable) error {
+	bd := entities.Ingredients{}
+	err := json.Unmarshal(api.rw.Body.Bytes(), &bd)
+	if err != nil {
+		return err
+	}
+
+	// first row is the header
+	expected := table.Rows[1:]
+	if len(bd) != len(expected) {
+		return fmt.Errorf("expected %d ingredients, got %d", len(expected), len(bd))
+	}
+
+	for i, row := range expected {
+		name := row.Cells[0].Value
+		unit := row.Cells[2].Value
+		quantity, err := strconv.Atoi(row.Cells[1].Value)
+		if err != nil {
+			return err
+		}
+
+		if bd[i].Name != name || bd[i].Quantity != quantity || bd[i].Unit != unit {
+			return fmt.Errorf("expected ingredient %s %d%s, got %s %d%s", name, quantity, unit, bd[i].Name, bd[i].Quantity, bd[i].Unit)
+		}
+	}
+
+	return nil
+}
+
+func (api *V1APIFeature) theResponseHeaderShouldBe(header, value string) error {
+	if actual := api.rw.Header().Get(header); actual != value {
+		return fmt.Errorf("expected header %s does not match actual, %v vs. %v", header, value, actual)
+	}
+	return nil
+}
+
+func (api *V1APIFeature) productsShouldBeReturned(count int) error {
+	bd := []map[string]interface{}{}
+
+	err := json.Unmarshal(api.rw.Body.Bytes(), &bd)
+	if err != nil {
+		return err
+	}
+
+	if len(bd) != count {
+		return fmt.Errorf("expected %d products, got %d", count, len(bd))
+	}
+	return nil
+}
+
+func (api *V1APIFeature) theFirstProductsShouldBe(path, expected string) error {
+	bd := []interface{}{}
+
+	err := json.Unmarshal(api.rw.Body.Bytes(), &bd)
+	if err != nil {
+		return err
+	}
+
+	if len(bd) == 0 {
+		return fmt.Errorf("expected a list of products, got an empty list")
+	}
+
+	// Walk the dot separated path, e.g. ingredients.0.name
+	var value interface{} = bd[0]
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			value = node[key]
+		case []interface{}:
+			n, err := strconv.Atoi(key)
+			if err != nil || n >= len(node) {
+				return fmt.Errorf("expected %s to exist in the first product", path)
+			}
+			value = node[n]
+		default:
+			return fmt.Errorf("expected %s to exist in the first product", path)
 		}
-	default:
+	}
+
+	if actual := fmt.Sprint(value); actual != expected {
+		return fmt.Errorf("expected the first product's %s to be %s, got %s", path, expected, actual)
+	}
+
+	return nil
+}
+
+func (api *V1APIFeature) theResponseStatusShouldBe(statusCode string) error {
+	statusCodes := map[string]int{
+		"OK":             http.StatusOK,
+		"Created":        http.StatusCreated,
+		"No Content":     http.StatusNoContent,
+		"Not Modified":   http.StatusNotModified,
+		"Bad Request":    http.StatusBadRequest,
+		"Not Found":      http.StatusNotFound,
+		"Conflict":       http.StatusConflict,
+		"Not Acceptable": http.StatusNotAcceptable,
+	}
+
+	expected, ok := statusCodes[statusCode]
+	if !ok {
 		return fmt.Errorf("Status Code is not valid, %s", statusCode)
 	}
+
+	if api.rw.Code != expected {
+		return fmt.Errorf("expected status code does not match actual, %v vs. %v", expected, api.rw.Code)
+	}
+
 	return nil
 }
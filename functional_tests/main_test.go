@@ -10,7 +10,7 @@ import (
 
 	"github.com/cucumber/godog"
 	"github.com/cucumber/godog/colors"
-	v1 "github.com/hashicorp-demoapp/coffee-service/service/v1"
+	"github.com/gorilla/mux"
 )
 
 var runTest *bool = flag.Bool("run.test", false, "Should we run the tests")
@@ -53,27 +53,28 @@ func TestMain(m *testing.M) {
 }
 
 type V1APIFeature struct {
-	svc *v1.CoffeeService
-	rw  *httptest.ResponseRecorder
-	r   *http.Request
+	router *mux.Router
+	rw     *httptest.ResponseRecorder
+	r      *http.Request
 }
 
 func FeatureContext(s *godog.Suite) {
 	v1api := &V1APIFeature{}
 
-	v1api.initService()
-
-	v1api.initHandlers()
-
-	// TODO: do we want this functionality
-	//s.Step(`^the server is running$`, v1api.theServerIsRunning)
+	s.Step(`^the server is running$`, v1api.theServerIsRunning)
 
 	s.Step(`^I make a "([^"]*)" request to "([^"]*)"$`, v1api.iMakeARequestTo)
 	s.Step(`^I make a "([^"]*)" request to "([^"]*)" where "([^"]*)" is "([^"]*)"$`, v1api.iMakeARequestToWhereIs)
 	s.Step(`^I make a "([^"]*)" request to "([^"]*)" with the following request body:$`, v1api.iMakeARequestToWithTheFollowingRequestBody)
+	s.Step(`^I make a "([^"]*)" request to "([^"]*)" with the header "([^"]*)" set to "([^"]*)"$`, v1api.iMakeARequestToWithTheHeaderSetTo)
+	s.Step(`^I make a "([^"]*)" request to "([^"]*)" with the header "([^"]*)" set to the response header "([^"]*)"$`, v1api.iMakeARequestToWithTheHeaderSetToTheResponseHeader)
 
 	s.Step(`^a list of products should be returned$`, v1api.aListOfProductsShouldBeReturned)
 	s.Step(`^a list of the product\'s ingredients should be returned$`, v1api.thatProductsIngredientsShouldBeReturned)
+	s.Step(`^the ingredients should be:$`, v1api.theIngredientsShouldBe)
+	s.Step(`^(\d+) products should be returned$`, v1api.productsShouldBeReturned)
+	s.Step(`^the response header "([^"]*)" should be "([^"]*)"$`, v1api.theResponseHeaderShouldBe)
+	s.Step(`^the first product\'s "([^"]*)" should be "([^"]*)"$`, v1api.theFirstProductsShouldBe)
 
 	s.Step(`^the response status should be "([^"]*)"$`, v1api.theResponseStatusShouldBe)
 }
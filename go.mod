@@ -3,13 +3,12 @@ module github.com/hashicorp-demoapp/coffee-service
 go 1.14
 
 require (
-	contrib.go.opencensus.io/integrations/ocsql v0.1.6
+	github.com/DATA-DOG/go-sqlmock v1.5.0
 	github.com/codahale/hdrhistogram v0.9.0 // indirect
 	github.com/cucumber/godog v0.10.0
 	github.com/cucumber/messages-go/v10 v10.0.3
 	github.com/fatih/color v1.9.0 // indirect
 	github.com/go-sql-driver/mysql v1.4.1 // indirect
-	github.com/google/go-cmp v0.4.0 // indirect
 	github.com/gorilla/mux v1.8.0
 	github.com/hashicorp-demoapp/go-hckit v0.0.1
 	github.com/hashicorp/go-hclog v0.14.1
@@ -18,17 +17,20 @@ require (
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/lib/pq v1.2.0
 	github.com/mattn/go-colorable v0.1.6 // indirect
-	github.com/mattn/go-sqlite3 v1.11.0 // indirect
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/nicholasjackson/env v0.6.0
-	github.com/opentracing/opentracing-go v1.2.0
-	github.com/pkg/errors v0.8.1 // indirect
+	github.com/prometheus/client_golang v1.12.2
 	github.com/stretchr/objx v0.2.0 // indirect
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/testify v1.7.0
 	github.com/uber/jaeger-client-go v2.25.0+incompatible // indirect
 	github.com/uber/jaeger-lib v2.2.0+incompatible // indirect
-	go.opencensus.io v0.22.0 // indirect
+	go.opentelemetry.io/contrib/propagators/b3 v1.0.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
 	go.uber.org/atomic v1.4.0 // indirect
-	google.golang.org/appengine v1.6.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
 
 // replace github.com/DerekStrickland/learn-consul-jaeger/go-hckit => /Users/derekstrickland/code/DerekStrickland/learn-consul-jaeger/go-hckit
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/metrics"
 	"github.com/hashicorp-demoapp/coffee-service/service"
+	"github.com/hashicorp-demoapp/coffee-service/service/problem"
+	"github.com/hashicorp-demoapp/coffee-service/tracing"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/nicholasjackson/env"
-
-	// opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel"
 )
 
 func main() {
@@ -40,6 +49,50 @@ func main() {
 	// Lifecycle event
 	cfg.Logger.Info("Finished loading configuration from environment")
 
+	if cfg.DBTraceEnabled {
+		cfg.Logger.Warn(fmt.Sprintf("%s is deprecated and ignored, the repository queries are always traced", config.DBTraceEnabled))
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			err = runMigrate(cfg, args[1:])
+		case "seed":
+			err = runSeed(cfg, args[1:])
+		default:
+			// Unrecoverable error
+			cfg.Logger.Error("Unknown command, expected migrate or seed", "command", args[0])
+			os.Exit(2)
+		}
+
+		if err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to run command", "command", args[0], "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Component initialization
+	cfg.Logger.Info("Initializing metrics")
+	serviceMetrics := metrics.New()
+	// Component initialized
+	cfg.Logger.Info("Metrics initialized")
+
+	// Component initialization
+	cfg.Logger.Info("Initializing tracing", "endpoint", cfg.Tracing.Endpoint, "sample_ratio", cfg.Tracing.SampleRatio)
+	tracerProvider, err := tracing.NewProvider(context.Background(), cfg.Tracing)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(tracing.NewPropagator())
+	serviceTracing := tracing.New(tracerProvider, otel.GetTextMapPropagator(), cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("Tracing initialized")
+
 	// Lifecycle event
 	cfg.Logger.Info("Initializing router")
 	router := mux.NewRouter()
@@ -47,51 +100,203 @@ func main() {
 	/*
 	   Configure middleware here
 	*/
+	router.Use(service.RequestID)
+	router.Use(serviceTracing.Middleware)
+	router.Use(serviceMetrics.Middleware)
 
 	// Lifecycle event
 	cfg.Logger.Info("Router initialized")
 
 	// Lifecycle event
 	cfg.Logger.Info("Registering not found handler")
-	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
-	})
+	router.NotFoundHandler = serviceTracing.Middleware(serviceMetrics.Middleware(service.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.NotFound(fmt.Sprintf("No route matches %s", r.URL.Path)))
+	}))))
+	router.MethodNotAllowedHandler = serviceTracing.Middleware(serviceMetrics.Middleware(service.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.New(http.StatusMethodNotAllowed, fmt.Sprintf("Method %s is not allowed on %s", r.Method, r.URL.Path)))
+	}))))
+
+	// Component initialization
+	cfg.Logger.Info(fmt.Sprintf("Initializing repository for backend %s", cfg.DBBackend))
+	repository, err := data.NewRepository(cfg)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to initialize repository", "error", err)
+		os.Exit(1)
+	}
+	instrumented, err := serviceMetrics.NewRepository(repository, cfg.DBBackend.String())
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to instrument repository", "error", err)
+		os.Exit(1)
+	}
+	repository = instrumented
+
+	database := service.Dependency{Name: "database", Pinger: repository}
+	if cfg.CircuitBreaker.FailureThreshold > 0 {
+		// Calls failing fast are not queries, the breaker wraps the metrics
+		breaker := data.NewCircuitBreaker(repository, cfg.CircuitBreaker)
+		if err := serviceMetrics.RegisterCircuitBreaker(breaker, cfg.DBBackend.String()); err != nil {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to instrument circuit breaker", "error", err)
+			os.Exit(1)
+		}
+		repository = breaker
+		database = service.Dependency{Name: "database", Pinger: breaker, State: func() string { return breaker.State().String() }}
+	}
+
+	repository = serviceTracing.NewRepository(repository, cfg.DBBackend)
+	// Component initialized
+	cfg.Logger.Info("Repository initialized")
+
+	// The purge job stops before the repository is closed
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	var purging sync.WaitGroup
+	if cfg.Purge.Retention > 0 {
+		// Lifecycle event
+		cfg.Logger.Info("Starting purge job", "retention", cfg.Purge.Retention, "interval", cfg.Purge.Interval)
+		purging.Add(1)
+		go func() {
+			defer purging.Done()
+			data.NewPurger(repository, cfg.Purge, cfg.Logger).Run(purgeCtx)
+		}()
+	}
 
 	// Component initialization
 	cfg.Logger.Info("Initializing HealthService")
-	healthService := service.NewHealth(cfg.Logger)
+	healthService := service.NewHealth(cfg.Logger, cfg.HealthCheckTimeout, database)
 	// Component initialized
 	cfg.Logger.Info("HealthService initialized")
 
 	// Lifecycle event
 	cfg.Logger.Info("Registering health handler")
 	router.Handle("/health", healthService).Methods("GET")
+	router.HandleFunc("/health/live", healthService.Live).Methods("GET")
+	router.HandleFunc("/health/ready", healthService.Ready).Methods("GET")
 	// Lifecycle event
 	cfg.Logger.Info("Health handler registered")
 
 	// Component initialization
-	cfg.Logger.Info(fmt.Sprintf("Initializing CoffeeService version %s", cfg.Version))
-	coffeeService, err := service.NewCoffee(cfg)
+	cfg.Logger.Info("Initializing CoffeeService versions")
+	coffeeVersions, err := service.NewCoffeeVersions(cfg, repository)
+	if err != nil {
+		// Unrecoverable error
+		cfg.Logger.Error("Unable to initialize CoffeeService", "error", err)
+		os.Exit(1)
+	}
+	coffeeService, err := service.NewVersionedCoffee(cfg, coffeeVersions)
 	if err != nil {
 		// Unrecoverable error
 		cfg.Logger.Error("Unable to initialize CoffeeService", "error", err)
 		os.Exit(1)
 	}
 	// Component initialized
-	cfg.Logger.Info("CoffeeService initialized")
+	cfg.Logger.Info("CoffeeService initialized", "default_version", cfg.Version)
 
 	// Lifecycle event
 	cfg.Logger.Info("Registering coffee handler")
-	router.Handle("/coffees", coffeeService).Methods("GET")
+	for _, version := range config.Versions {
+		versionRouter := router.PathPrefix("/" + version.String()).Subrouter()
+		versionRouter.Use(service.VersionHeaders(cfg, version))
+		service.RegisterCoffeeRoutes(versionRouter, coffeeVersions[version], cfg.CacheControl.Coffees)
+	}
+	service.RegisterCoffeeRoutes(router, coffeeService, cfg.CacheControl.Coffees)
 	// Lifecycle event
 	cfg.Logger.Info("Coffee handler registered")
 
+	// Component initialization
+	cfg.Logger.Info("Initializing IngredientService")
+	ingredientService := service.NewIngredient(repository, cfg.Logger)
+	// Component initialized
+	cfg.Logger.Info("IngredientService initialized")
+
+	// Lifecycle event
+	cfg.Logger.Info("Registering ingredient handler")
+	service.RegisterIngredientRoutes(router, ingredientService, cfg.CacheControl.Ingredients)
+	// Lifecycle event
+	cfg.Logger.Info("Ingredient handler registered")
+
+	var metricsServer *http.Server
+	if cfg.MetricsAddress != "" {
+		metricsServer = &http.Server{
+			Addr:         cfg.MetricsAddress,
+			Handler:      serviceMetrics.NewServeMux(),
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+		}
+
+		// Lifecycle event
+		cfg.Logger.Info("Starting metrics listener", "bind", cfg.MetricsAddress)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				// Unrecoverable error
+				cfg.Logger.Error("Unable to start metrics server.", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	server := &http.Server{
+		Addr:         cfg.BindAddress,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ErrorLog:     cfg.Logger.StandardLogger(&hclog.StandardLoggerOptions{InferLevels: true}),
+	}
+
 	// Lifecycle event
 	cfg.Logger.Info("Starting service listener", "bind", cfg.BindAddress)
-	err = http.ListenAndServe(cfg.BindAddress, router)
-	if err != nil {
-		// Unrecoverable error
-		cfg.Logger.Error("Unable to start server.", "error", err)
-		os.Exit(1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			// Unrecoverable error
+			cfg.Logger.Error("Unable to start server.", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+
+	// Lifecycle event
+	cfg.Logger.Info("Shutting down, failing readiness", "signal", sig, "delay", cfg.Server.ShutdownDelay)
+	healthService.ShutDown()
+	// Keep serving until the load balancers notice the failing readiness check
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	// Lifecycle event
+	cfg.Logger.Info("Draining connections", "grace_period", cfg.Server.ShutdownGracePeriod)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownGracePeriod)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		cfg.Logger.Error("Unable to drain connections before the grace period elapsed", "error", err)
+	}
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			cfg.Logger.Error("Unable to stop metrics server", "error", err)
+		}
 	}
+
+	// A provider without exporter has no spans to flush and fails to shut down
+	if cfg.Tracing.Endpoint != "" {
+		if err := tracerProvider.Shutdown(ctx); err != nil {
+			cfg.Logger.Error("Unable to flush spans", "error", err)
+		}
+	}
+
+	stopPurge()
+	purging.Wait()
+
+	// Lifecycle event
+	cfg.Logger.Info("Closing repository")
+	if err := repository.Close(); err != nil {
+		cfg.Logger.Error("Unable to close repository", "error", err)
+	}
+
+	// Lifecycle event
+	cfg.Logger.Info("Stopped coffee-service")
 }
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// versionHeader is the response header reporting the API version that served
+// the request.
+const versionHeader = "API-Version"
+
+// Middleware records the count and latency of the requests served by the
+// router, labelled with the route template rather than the path so that ids
+// do not create new series.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		version := rw.Header().Get(versionHeader)
+		if version == "" {
+			version = "none"
+		}
+
+		status := strconv.Itoa(recorder.status)
+		m.httpRequests.WithLabelValues(route, r.Method, status, version).Inc()
+		m.httpRequestDuration.WithLabelValues(route, r.Method, status, version).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code before writing it
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 status of handlers not calling WriteHeader
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
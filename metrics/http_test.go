@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRouter(m *Metrics) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	router.HandleFunc("/coffees/{id:[0-9]+}", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set(versionHeader, "v2")
+		rw.WriteHeader(http.StatusNotFound)
+	})
+	router.HandleFunc("/coffees", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("[]"))
+	})
+
+	return router
+}
+
+func TestMiddlewareLabelsRequestsWithRouteTemplate(t *testing.T) {
+	m := New()
+	router := setupRouter(m)
+
+	for _, path := range []string{"/coffees/1", "/coffees/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.httpRequests.WithLabelValues("/coffees/{id:[0-9]+}", "GET", "404", "v2")))
+}
+
+func TestMiddlewareRecordsImplicitStatusAndMissingVersion(t *testing.T) {
+	m := New()
+	router := setupRouter(m)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.httpRequests.WithLabelValues("/coffees", "GET", "200", "none")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.httpRequestDuration))
+}
+
+func TestHandlerServesMetrics(t *testing.T) {
+	m := New()
+	setupRouter(m).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+
+	rw := httptest.NewRecorder()
+	m.NewServeMux().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.True(t, strings.Contains(rw.Body.String(), "coffee_service_http_requests_total"))
+	assert.True(t, strings.Contains(rw.Body.String(), "go_goroutines"))
+}
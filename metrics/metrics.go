@@ -0,0 +1,112 @@
+// Package metrics exposes the Prometheus metrics of the service: HTTP
+// requests, repository queries, the database connection pool and the Go
+// runtime.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// namespace prefixes the name of every metric of the service
+const namespace = "coffee_service"
+
+// Metrics holds the collectors of the service in their own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	queryDuration       *prometheus.HistogramVec
+	queryErrors         *prometheus.CounterVec
+}
+
+// New creates the metrics of the service, including the Go runtime and process
+// metrics.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests handled, by route, method, status code and API version.",
+		}, []string{"route", "method", "status", "version"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the HTTP requests, by route, method, status code and API version.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status", "version"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "query_duration_seconds",
+			Help:      "Latency of the repository queries, by backend and operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"backend", "operation"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "errors_total",
+			Help:      "Number of repository queries that failed unexpectedly, by backend and operation.",
+		}, []string{"backend", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.queryDuration,
+		m.queryErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Register adds collectors to the registry of the service
+func (m *Metrics) Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RegisterCircuitBreaker exports the state of the circuit breaker of the
+// repository of the backend: 0 closed, 1 open and 2 half-open.
+func (m *Metrics) RegisterCircuitBreaker(breaker *data.CircuitBreaker, backend string) error {
+	return m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Subsystem:   "circuit_breaker",
+		Name:        "state",
+		Help:        "State of the circuit breaker of the repository, 0 closed, 1 open and 2 half-open.",
+		ConstLabels: prometheus.Labels{"backend": backend},
+	}, func() float64 {
+		return float64(breaker.State())
+	}))
+}
+
+// Handler returns the handler serving the metrics in the Prometheus
+// exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// NewServeMux returns the mux of the metrics listener, serving the metrics on
+// /metrics.
+func (m *Metrics) NewServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+
+	return mux
+}
@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Repository is a data.Repository decorator recording the latency and the
+// errors of every query made to the wrapped repository.
+type Repository struct {
+	next    data.Repository
+	backend string
+	metrics *Metrics
+}
+
+// NewRepository wraps the repository of the backend with instrumentation. When
+// the repository reports its connection pool statistics they are registered as
+// well.
+func (m *Metrics) NewRepository(next data.Repository, backend string) (*Repository, error) {
+	if reporter, ok := next.(data.StatsReporter); ok {
+		if err := m.Register(newDBStatsCollector(reporter, backend)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Repository{next, backend, m}, nil
+}
+
+// observe records a query of the operation started at start. Errors
+// describing the request, rather than a failure of the database, are not
+// counted.
+func (r *Repository) observe(operation string, start time.Time, err error) {
+	r.metrics.queryDuration.WithLabelValues(r.backend, operation).Observe(time.Since(start).Seconds())
+
+	if err == nil || data.IsRequestError(err) {
+		return
+	}
+
+	r.metrics.queryErrors.WithLabelValues(r.backend, operation).Inc()
+}
+
+// Find implements data.Repository
+func (r *Repository) Find(ctx context.Context, query data.CoffeeQuery) (page *data.CoffeePage, err error) {
+	defer func(start time.Time) { r.observe("find", start, err) }(time.Now())
+	return r.next.Find(ctx, query)
+}
+
+// FindByID implements data.Repository
+func (r *Repository) FindByID(ctx context.Context, id int, includeDeleted bool) (coffee *entities.Coffee, err error) {
+	defer func(start time.Time) { r.observe("find_by_id", start, err) }(time.Now())
+	return r.next.FindByID(ctx, id, includeDeleted)
+}
+
+// FindIngredients implements data.Repository
+func (r *Repository) FindIngredients(ctx context.Context, coffeeID int) (ingredients entities.Ingredients, err error) {
+	defer func(start time.Time) { r.observe("find_ingredients", start, err) }(time.Now())
+	return r.next.FindIngredients(ctx, coffeeID)
+}
+
+// CreateCoffee implements data.Repository
+func (r *Repository) CreateCoffee(ctx context.Context, coffee entities.Coffee) (created *entities.Coffee, err error) {
+	defer func(start time.Time) { r.observe("create_coffee", start, err) }(time.Now())
+	return r.next.CreateCoffee(ctx, coffee)
+}
+
+// UpdateCoffee implements data.Repository
+func (r *Repository) UpdateCoffee(ctx context.Context, coffee entities.Coffee) (updated *entities.Coffee, err error) {
+	defer func(start time.Time) { r.observe("update_coffee", start, err) }(time.Now())
+	return r.next.UpdateCoffee(ctx, coffee)
+}
+
+// DeleteCoffee implements data.Repository
+func (r *Repository) DeleteCoffee(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) { r.observe("delete_coffee", start, err) }(time.Now())
+	return r.next.DeleteCoffee(ctx, id)
+}
+
+// RestoreCoffee implements data.Repository
+func (r *Repository) RestoreCoffee(ctx context.Context, id int) (coffee *entities.Coffee, err error) {
+	defer func(start time.Time) { r.observe("restore_coffee", start, err) }(time.Now())
+	return r.next.RestoreCoffee(ctx, id)
+}
+
+// Purge implements data.Repository
+func (r *Repository) Purge(ctx context.Context, before time.Time) (purged int, err error) {
+	defer func(start time.Time) { r.observe("purge", start, err) }(time.Now())
+	return r.next.Purge(ctx, before)
+}
+
+// Revision implements data.Repository
+func (r *Repository) Revision(ctx context.Context) (revision time.Time, err error) {
+	defer func(start time.Time) { r.observe("revision", start, err) }(time.Now())
+	return r.next.Revision(ctx)
+}
+
+// ListIngredients implements data.Repository
+func (r *Repository) ListIngredients(ctx context.Context, query data.IngredientQuery) (ingredients entities.Ingredients, err error) {
+	defer func(start time.Time) { r.observe("list_ingredients", start, err) }(time.Now())
+	return r.next.ListIngredients(ctx, query)
+}
+
+// FindIngredientByID implements data.Repository
+func (r *Repository) FindIngredientByID(ctx context.Context, id int, includeDeleted bool) (ingredient *entities.Ingredient, err error) {
+	defer func(start time.Time) { r.observe("find_ingredient_by_id", start, err) }(time.Now())
+	return r.next.FindIngredientByID(ctx, id, includeDeleted)
+}
+
+// CreateIngredient implements data.Repository
+func (r *Repository) CreateIngredient(ctx context.Context, ingredient entities.Ingredient) (created *entities.Ingredient, err error) {
+	defer func(start time.Time) { r.observe("create_ingredient", start, err) }(time.Now())
+	return r.next.CreateIngredient(ctx, ingredient)
+}
+
+// UpdateIngredient implements data.Repository
+func (r *Repository) UpdateIngredient(ctx context.Context, ingredient entities.Ingredient) (updated *entities.Ingredient, err error) {
+	defer func(start time.Time) { r.observe("update_ingredient", start, err) }(time.Now())
+	return r.next.UpdateIngredient(ctx, ingredient)
+}
+
+// DeleteIngredient implements data.Repository
+func (r *Repository) DeleteIngredient(ctx context.Context, id int) (err error) {
+	defer func(start time.Time) { r.observe("delete_ingredient", start, err) }(time.Now())
+	return r.next.DeleteIngredient(ctx, id)
+}
+
+// Ping implements data.Repository
+func (r *Repository) Ping(ctx context.Context) (err error) {
+	defer func(start time.Time) { r.observe("ping", start, err) }(time.Now())
+	return r.next.Ping(ctx)
+}
+
+// Close implements data.Repository
+func (r *Repository) Close() error {
+	return r.next.Close()
+}
+
+// dbStatsCollector exports the statistics of a database/sql connection pool
+type dbStatsCollector struct {
+	reporter data.StatsReporter
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+func newDBStatsCollector(reporter data.StatsReporter, backend string) *dbStatsCollector {
+	labels := prometheus.Labels{"backend": backend}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db", name), help, nil, labels)
+	}
+
+	return &dbStatsCollector{
+		reporter:          reporter,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("open_connections", "Number of established connections, in use or idle."),
+		inUse:             desc("in_use_connections", "Number of connections currently in use."),
+		idle:              desc("idle_connections", "Number of idle connections."),
+		waitCount:         desc("wait_count_total", "Number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "Total time blocked waiting for a new connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "Number of connections closed due to the maximum of idle connections."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Number of connections closed due to their maximum lifetime."),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxLifetimeClosed
+}
+
+// Collect implements prometheus.Collector
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.reporter.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
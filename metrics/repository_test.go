@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// statsRepository is a mock repository reporting connection pool statistics
+type statsRepository struct {
+	*data.MockRepository
+}
+
+func (statsRepository) Stats() sql.DBStats {
+	return sql.DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 1, Idle: 2}
+}
+
+func TestRepositoryRecordsQueryLatency(t *testing.T) {
+	mr := &data.MockRepository{}
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(&entities.Coffee{ID: 1}, nil)
+
+	m := New()
+	r, err := m.NewRepository(mr, "memory")
+	require.NoError(t, err)
+
+	coffee, err := r.FindByID(context.Background(), 1, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, coffee.ID)
+	assert.Equal(t, 1, testutil.CollectAndCount(m.queryDuration))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.queryErrors))
+}
+
+func TestRepositoryCountsUnexpectedErrorsOnly(t *testing.T) {
+	mr := &data.MockRepository{}
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, errors.New("connection reset"))
+	mr.On("FindByID", mock.Anything, 2, mock.Anything).Return(nil, data.ErrNotFound)
+	mr.On("DeleteIngredient", mock.Anything, 1).Return(data.ErrConflict)
+
+	m := New()
+	r, err := m.NewRepository(mr, "postgres")
+	require.NoError(t, err)
+
+	r.FindByID(context.Background(), 1, false)
+	r.FindByID(context.Background(), 2, false)
+	r.DeleteIngredient(context.Background(), 1)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.queryErrors.WithLabelValues("postgres", "find_by_id")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.queryErrors.WithLabelValues("postgres", "delete_ingredient")))
+}
+
+func TestRepositoryRegistersConnectionPoolStatistics(t *testing.T) {
+	m := New()
+	_, err := m.NewRepository(statsRepository{&data.MockRepository{}}, "postgres")
+	require.NoError(t, err)
+
+	expected := `
+# HELP coffee_service_db_open_connections Number of established connections, in use or idle.
+# TYPE coffee_service_db_open_connections gauge
+coffee_service_db_open_connections{backend="postgres"} 3
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "coffee_service_db_open_connections"))
+}
+
+func TestRegisterCircuitBreakerExportsState(t *testing.T) {
+	m := New()
+	breaker := data.NewCircuitBreaker(&data.MockRepository{}, config.CircuitBreaker{FailureThreshold: 1})
+	require.NoError(t, m.RegisterCircuitBreaker(breaker, "postgres"))
+
+	expected := `
+# HELP coffee_service_circuit_breaker_state State of the circuit breaker of the repository, 0 closed, 1 open and 2 half-open.
+# TYPE coffee_service_circuit_breaker_state gauge
+coffee_service_circuit_breaker_state{backend="postgres"} 0
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "coffee_service_circuit_breaker_state"))
+}
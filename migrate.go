@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// migrateUsage describes the arguments of the migrate command
+const migrateUsage = "usage: coffee-service migrate up|down|status|to VERSION"
+
+// runMigrate runs the migrate command, applying or reverting the schema
+// migrations of the Postgres database
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	var version int
+	switch args[0] {
+	case "up", "down", "status":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+
+		var err error
+		if version, err = strconv.Atoi(args[1]); err != nil || version < 0 {
+			return fmt.Errorf("version must be a positive number, or 0 to revert every migration, got %q", args[1])
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := data.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "to":
+		return migrator.To(ctx, version)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		if status.Baselined {
+			fmt.Fprintf(w, "%d\t%s\tbaselined\t%s\n", status.Version, status.Name, status.AppliedAt.Format(time.RFC3339))
+		} else if status.Applied {
+			fmt.Fprintf(w, "%d\t%s\tapplied\t%s\n", status.Version, status.Name, status.AppliedAt.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(w, "%d\t%s\tpending\t\n", status.Version, status.Name)
+		}
+	}
+
+	return w.Flush()
+}
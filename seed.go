@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+)
+
+// runSeed runs the seed command, loading the seed of the configuration into
+// the Postgres or SQLite database
+func runSeed(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	replace := flags.Bool("replace", false, "Replace the menu of a database that already has coffees")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	seed, err := data.LoadSeed(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := data.SeedDatabase(context.Background(), cfg, seed, *replace); err != nil {
+		if err == data.ErrNotEmpty {
+			cfg.Logger.Info("Database already has coffees, use -replace to replace the menu")
+		}
+		return err
+	}
+
+	cfg.Logger.Info("Seeded database", "backend", cfg.DBBackend, "coffees", len(seed.Coffees), "ingredients", len(seed.Ingredients))
+	return nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"net/http"
+)
+
+// CacheControl is middleware setting the Cache-Control header of the
+// successful responses, and of the 304 answering a conditional GET. Errors are
+// not cached, and the header is left out when value is empty.
+func CacheControl(value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if value == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: rw, value: value}, r)
+		})
+	}
+}
+
+// cacheControlWriter sets the Cache-Control header once the status of the
+// response is known
+type cacheControlWriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusOK || status == http.StatusNotModified {
+			w.Header().Set("Cache-Control", w.value)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
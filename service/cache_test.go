@@ -0,0 +1,47 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp-demoapp/coffee-service/service/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveCacheControl(value string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+
+	CacheControl(value)(handler).ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+
+	return rw
+}
+
+func TestCacheControlSetsHeaderOfSuccessfulResponses(t *testing.T) {
+	cases := map[string]http.HandlerFunc{
+		"implicit ok":  func(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte("[]")) },
+		"ok":           func(rw http.ResponseWriter, r *http.Request) { rw.WriteHeader(http.StatusOK) },
+		"not modified": func(rw http.ResponseWriter, r *http.Request) { rw.WriteHeader(http.StatusNotModified) },
+	}
+
+	for name, handler := range cases {
+		rw := serveCacheControl("no-cache", handler)
+
+		assert.Equal(t, "no-cache", rw.Header().Get("Cache-Control"), name)
+	}
+}
+
+func TestCacheControlDoesNotCacheErrors(t *testing.T) {
+	rw := serveCacheControl("max-age=60", func(rw http.ResponseWriter, r *http.Request) {
+		problem.Write(rw, r, problem.NotFound("Coffee not found"))
+	})
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Empty(t, rw.Header().Get("Cache-Control"))
+}
+
+func TestCacheControlIsDisabledWhenEmpty(t *testing.T) {
+	rw := serveCacheControl("", func(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte("[]")) })
+
+	assert.Empty(t, rw.Header().Get("Cache-Control"))
+}
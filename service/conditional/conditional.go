@@ -0,0 +1,85 @@
+// Package conditional writes the responses of the api with the ETag and
+// Last-Modified validators, answering the conditional GET requests of clients
+// that already hold the representation with 304 Not Modified, as defined by
+// RFC 7232.
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag returns the strong entity tag of the body, derived from its content so
+// every replica of the service tags the same representation alike
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// Write writes the body with a 200 status, unless the request is a
+// conditional GET for a representation the client holds, which is answered
+// with 304 and no body. Last-Modified is left out when modified is zero, or
+// when its second is not over as a later change would not move it.
+func Write(rw http.ResponseWriter, r *http.Request, body []byte, modified time.Time) {
+	etag := ETag(body)
+
+	if !modified.Truncate(time.Second).Before(time.Now().Truncate(time.Second)) {
+		modified = time.Time{}
+	}
+
+	rw.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		rw.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, modified) {
+		rw.Header().Del("Content-Type")
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(body)
+}
+
+// notModified evaluates the preconditions of a GET request. If-Modified-Since
+// is ignored when If-None-Match is present, the entity tag is the stronger
+// validator.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Values("If-None-Match"); len(inm) > 0 {
+		return matchETag(strings.Join(inm, ","), etag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	// Last-Modified has a precision of a second
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// matchETag reports whether the If-None-Match list contains the entity tag,
+// using the weak comparison required for GET requests
+func matchETag(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+package conditional
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var body = []byte(`[{"id":1,"name":"Packer Spiced Latte"}]`)
+
+var modified = time.Date(2021, 3, 1, 12, 0, 0, 500, time.UTC)
+
+func write(method string, headers map[string]string) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest(method, "/coffees", nil)
+	for header, value := range headers {
+		r.Header.Set(header, value)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	Write(rw, r, body, modified)
+
+	return rw
+}
+
+func TestETagIsStrongAndDependsOnTheBody(t *testing.T) {
+	etag := ETag(body)
+
+	assert.Regexp(t, `^"[0-9a-f]{64}"$`, etag)
+	assert.Equal(t, etag, ETag(body))
+	assert.NotEqual(t, etag, ETag([]byte(`[]`)))
+}
+
+func TestWriteWritesBodyWithValidators(t *testing.T) {
+	rw := write("GET", nil)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, body, rw.Body.Bytes())
+	assert.Equal(t, ETag(body), rw.Header().Get("ETag"))
+	assert.Equal(t, "Mon, 01 Mar 2021 12:00:00 GMT", rw.Header().Get("Last-Modified"))
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+}
+
+func TestWriteLeavesOutLastModifiedWhenUnknown(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	Write(rw, httptest.NewRequest("GET", "/coffees", nil), []byte(`[]`), time.Time{})
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Empty(t, rw.Header().Get("Last-Modified"))
+}
+
+func TestWriteLeavesOutLastModifiedWithinItsSecond(t *testing.T) {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set("If-Modified-Since", time.Now().UTC().Add(time.Minute).Format(http.TimeFormat))
+
+	Write(rw, r, body, time.Now())
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Empty(t, rw.Header().Get("Last-Modified"))
+}
+
+func TestWriteAnswersMatchingIfNoneMatchWithNotModified(t *testing.T) {
+	cases := []string{
+		ETag(body),
+		"W/" + ETag(body),
+		`"other", ` + ETag(body),
+		"*",
+	}
+
+	for _, inm := range cases {
+		rw := write("GET", map[string]string{"If-None-Match": inm})
+
+		assert.Equal(t, http.StatusNotModified, rw.Code, inm)
+		assert.Empty(t, rw.Body.Bytes(), inm)
+		assert.Equal(t, ETag(body), rw.Header().Get("ETag"), inm)
+		assert.Empty(t, rw.Header().Get("Content-Type"), inm)
+	}
+}
+
+func TestWriteWritesBodyWhenIfNoneMatchDiffers(t *testing.T) {
+	rw := write("GET", map[string]string{"If-None-Match": `"other"`})
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, body, rw.Body.Bytes())
+}
+
+func TestWriteAnswersIfModifiedSinceWithNotModified(t *testing.T) {
+	cases := map[string]int{
+		"Mon, 01 Mar 2021 12:00:00 GMT": http.StatusNotModified,
+		"Mon, 01 Mar 2021 13:00:00 GMT": http.StatusNotModified,
+		"Mon, 01 Mar 2021 11:59:59 GMT": http.StatusOK,
+		"yesterday":                     http.StatusOK,
+	}
+
+	for ims, status := range cases {
+		rw := write("GET", map[string]string{"If-Modified-Since": ims})
+
+		assert.Equal(t, status, rw.Code, ims)
+	}
+}
+
+func TestWriteIgnoresIfModifiedSinceWithIfNoneMatch(t *testing.T) {
+	rw := write("GET", map[string]string{
+		"If-None-Match":     `"other"`,
+		"If-Modified-Since": "Mon, 01 Mar 2021 13:00:00 GMT",
+	})
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
+
+func TestWriteIgnoresPreconditionsOfCommands(t *testing.T) {
+	rw := write("PUT", map[string]string{"If-None-Match": "*"})
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, body, rw.Body.Bytes())
+	assert.Equal(t, ETag(body), rw.Header().Get("ETag"))
+}
@@ -0,0 +1,401 @@
+// Package handler serves the coffee api. The routes behave alike in every
+// version, which only differ in their representation of a coffee.
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	hclog "github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/service/conditional"
+	"github.com/hashicorp-demoapp/coffee-service/service/problem"
+)
+
+// Representation converts coffees to the json of a version of the api
+type Representation interface {
+	CoffeesJSON(ctx context.Context, coffees entities.Coffees) ([]byte, error)
+	CoffeeJSON(ctx context.Context, coffee *entities.Coffee) ([]byte, error)
+}
+
+// RepresentationFunc is a Representation mapping every coffee to the value
+// written as its json. The coffees carry the name and deletion of the
+// ingredients of their recipe, so the representation needs no other lookup.
+type RepresentationFunc func(coffee entities.Coffee) interface{}
+
+// CoffeesJSON implements Representation
+func (f RepresentationFunc) CoffeesJSON(ctx context.Context, coffees entities.Coffees) ([]byte, error) {
+	response := make([]interface{}, len(coffees))
+	for n, coffee := range coffees {
+		response[n] = f(coffee)
+	}
+
+	return json.Marshal(response)
+}
+
+// CoffeeJSON implements Representation
+func (f RepresentationFunc) CoffeeJSON(ctx context.Context, coffee *entities.Coffee) ([]byte, error) {
+	return json.Marshal(f(*coffee))
+}
+
+// CoffeeService is the service implementation for this microservice.
+type CoffeeService struct {
+	repository     data.Repository
+	representation Representation
+	logger         hclog.Logger
+}
+
+// NewCoffeeService is a factory method that returns a new instance of the
+// CoffeeService, writing the coffees in the representation.
+func NewCoffeeService(repository data.Repository, representation Representation, l hclog.Logger) *CoffeeService {
+	return &CoffeeService{repository, representation, l}
+}
+
+// ServeHTTP handles incoming requests for the api coffees route. The query
+// string supports the limit, cursor, sort, max_price, ingredient and
+// name_contains parameters of data.CoffeeQuery. Clients polling the coffees
+// revalidate them with If-None-Match or If-Modified-Since, the latter only when
+// the deleted coffees are left out.
+func (c *CoffeeService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	c.logger.Debug("Handle Coffees")
+
+	query, err := data.ParseCoffeeQuery(r.URL.Query())
+	if err != nil {
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	page, err := c.repository.Find(r.Context(), query)
+	if err != nil {
+		c.logger.Error("Unable to get coffees from database", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+	coffees := page.Coffees
+	c.logger.Debug(fmt.Sprintf("Found %d coffees", len(coffees)))
+
+	coffeesJSON, err := c.representation.CoffeesJSON(r.Context(), coffees)
+	if err != nil {
+		c.logger.Error("Unable to convert coffees to JSON", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	// The revision does not move when deleted coffees are purged
+	var modified time.Time
+	if !query.IncludeDeleted {
+		modified = c.lastModified(r.Context())
+	}
+
+	writePageHeaders(rw, r, query, page)
+	conditional.Write(rw, r, coffeesJSON, modified)
+}
+
+// GetCoffee handles incoming requests for the api coffees/{id} route. A
+// deleted coffee is only returned with include_deleted=true.
+func (c *CoffeeService) GetCoffee(rw http.ResponseWriter, r *http.Request) {
+	c.logger.Debug("Handle Coffee")
+
+	id, err := coffeeID(r)
+	if err != nil {
+		c.logger.Error("Unable to parse coffee id", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid coffee id"))
+		return
+	}
+
+	includeDeleted, err := data.ParseIncludeDeleted(r.URL.Query())
+	if err != nil {
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	coffee, err := c.repository.FindByID(r.Context(), id, includeDeleted)
+	if err == data.ErrNotFound {
+		c.logger.Debug(fmt.Sprintf("Coffee %d not found", id))
+		problem.Write(rw, r, problem.NotFound("Coffee not found"))
+		return
+	}
+	if err != nil {
+		c.logger.Error("Unable to get coffee from database", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	c.writeCoffee(rw, r, http.StatusOK, coffee)
+}
+
+// GetCoffeeIngredients handles incoming requests for the api coffees/{id}/ingredients route
+func (c *CoffeeService) GetCoffeeIngredients(rw http.ResponseWriter, r *http.Request) {
+	c.logger.Debug("Handle Coffee Ingredients")
+
+	id, err := coffeeID(r)
+	if err != nil {
+		c.logger.Error("Unable to parse coffee id", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid coffee id"))
+		return
+	}
+
+	ingredients, err := c.repository.FindIngredients(r.Context(), id)
+	if err == data.ErrNotFound {
+		c.logger.Debug(fmt.Sprintf("Coffee %d not found", id))
+		problem.Write(rw, r, problem.NotFound("Coffee not found"))
+		return
+	}
+	if err != nil {
+		c.logger.Error("Unable to get ingredients from database", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+	c.logger.Debug(fmt.Sprintf("Found %d ingredients", len(ingredients)))
+
+	ingredientsJSON, err := ingredients.ToJSON()
+	if err != nil {
+		c.logger.Error("Unable to convert ingredients to JSON", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	conditional.Write(rw, r, ingredientsJSON, c.lastModified(r.Context()))
+}
+
+// CreateCoffee handles POST requests for the api coffees route
+func (c *CoffeeService) CreateCoffee(rw http.ResponseWriter, r *http.Request) {
+	c.logger.Debug("Handle Create Coffee")
+
+	coffee := entities.Coffee{}
+	if err := coffee.FromJSON(r.Body); err != nil {
+		c.logger.Debug("Unable to parse coffee", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Unable to parse coffee"))
+		return
+	}
+	coffee.ID = 0
+
+	if err := coffee.Validate(); err != nil {
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	created, err := c.repository.CreateCoffee(r.Context(), coffee)
+	if c.handleWriteError(rw, r, err) {
+		return
+	}
+	c.logger.Debug(fmt.Sprintf("Created coffee %d", created.ID))
+
+	// The location is relative to the route, which may carry a version prefix
+	rw.Header().Set("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(r.URL.Path, "/"), created.ID))
+	c.writeCoffee(rw, r, http.StatusCreated, created)
+}
+
+// UpdateCoffee handles PUT requests for the api coffees/{id} route, replacing
+// the coffee and its ingredients.
+func (c *CoffeeService) UpdateCoffee(rw http.ResponseWriter, r *http.Request) {
+	c.logger.Debug("Handle Update Coffee")
+
+	id, err := coffeeID(r)
+	if err != nil {
+		c.logger.Error("Unable to parse coffee id", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid coffee id"))
+		return
+	}
+
+	coffee := entities.Coffee{}
+	if err := coffee.FromJSON(r.Body); err != nil {
+		c.logger.Debug("Unable to parse coffee", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Unable to parse coffee"))
+		return
+	}
+	coffee.ID = id
+
+	c.updateCoffee(rw, r, coffee)
+}
+
+// PatchCoffee handles PATCH requests for the api coffees/{id} route. Fields
+// present in the request body replace those of the stored coffee; when
+// ingredients are present they replace the whole recipe.
+func (c *CoffeeService) PatchCoffee(rw http.ResponseWriter, r *http.Request) {
+	c.logger.Debug("Handle Patch Coffee")
+
+	id, err := coffeeID(r)
+	if err != nil {
+		c.logger.Error("Unable to parse coffee id", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid coffee id"))
+		return
+	}
+
+	coffee, err := c.repository.FindByID(r.Context(), id, false)
+	if err == data.ErrNotFound {
+		problem.Write(rw, r, problem.NotFound("Coffee not found"))
+		return
+	}
+	if err != nil {
+		c.logger.Error("Unable to get coffee from database", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	if err := coffee.FromJSON(r.Body); err != nil {
+		c.logger.Debug("Unable to parse coffee", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Unable to parse coffee"))
+		return
+	}
+	coffee.ID = id
+
+	c.updateCoffee(rw, r, *coffee)
+}
+
+// DeleteCoffee handles DELETE requests for the api coffees/{id} route
+func (c *CoffeeService) DeleteCoffee(rw http.ResponseWriter, r *http.Request) {
+	c.logger.Debug("Handle Delete Coffee")
+
+	id, err := coffeeID(r)
+	if err != nil {
+		c.logger.Error("Unable to parse coffee id", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid coffee id"))
+		return
+	}
+
+	err = c.repository.DeleteCoffee(r.Context(), id)
+	if c.handleWriteError(rw, r, err) {
+		return
+	}
+	c.logger.Debug(fmt.Sprintf("Deleted coffee %d", id))
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreCoffee handles POST requests for the api coffees/{id}/restore route,
+// undoing the soft delete of a coffee that has not been purged yet.
+func (c *CoffeeService) RestoreCoffee(rw http.ResponseWriter, r *http.Request) {
+	c.logger.Debug("Handle Restore Coffee")
+
+	id, err := coffeeID(r)
+	if err != nil {
+		c.logger.Error("Unable to parse coffee id", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid coffee id"))
+		return
+	}
+
+	restored, err := c.repository.RestoreCoffee(r.Context(), id)
+	if c.handleWriteError(rw, r, err) {
+		return
+	}
+	c.logger.Debug(fmt.Sprintf("Restored coffee %d", id))
+
+	c.writeCoffee(rw, r, http.StatusOK, restored)
+}
+
+func (c *CoffeeService) updateCoffee(rw http.ResponseWriter, r *http.Request, coffee entities.Coffee) {
+	if err := coffee.Validate(); err != nil {
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	updated, err := c.repository.UpdateCoffee(r.Context(), coffee)
+	if c.handleWriteError(rw, r, err) {
+		return
+	}
+	c.logger.Debug(fmt.Sprintf("Updated coffee %d", updated.ID))
+
+	c.writeCoffee(rw, r, http.StatusOK, updated)
+}
+
+// handleWriteError writes the response for an error returned by a repository
+// command, and reports whether the request has been handled.
+func (c *CoffeeService) handleWriteError(rw http.ResponseWriter, r *http.Request, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if err == data.ErrNotFound {
+		problem.Write(rw, r, problem.NotFound("Coffee not found"))
+		return true
+	}
+
+	if err == data.ErrConflict {
+		p := problem.FromError(err)
+		p.Detail = "Coffee uses a deleted ingredient"
+		problem.Write(rw, r, p)
+		return true
+	}
+
+	if verr, ok := err.(*entities.ValidationError); ok {
+		problem.WriteError(rw, r, verr)
+		return true
+	}
+
+	c.logger.Error("Unable to write coffee to database", "error", err)
+	problem.WriteError(rw, r, err)
+	return true
+}
+
+func (c *CoffeeService) writeCoffee(rw http.ResponseWriter, r *http.Request, status int, coffee *entities.Coffee) {
+	coffeeJSON, err := c.representation.CoffeeJSON(r.Context(), coffee)
+	if err != nil {
+		c.logger.Error("Unable to convert coffee to JSON", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if status == http.StatusOK {
+		// The coffee is the current representation, it carries its validators
+		conditional.Write(rw, r, coffeeJSON, c.lastModified(r.Context()))
+		return
+	}
+
+	rw.WriteHeader(status)
+	rw.Write(coffeeJSON)
+}
+
+// lastModified returns the revision of the repository. The representations
+// embed the ingredients and depend on the other coffees matching a query, so
+// the update of a single row does not tell when they last changed. Zero is
+// returned, leaving out Last-Modified, when the revision is unavailable.
+func (c *CoffeeService) lastModified(ctx context.Context) time.Time {
+	revision, err := c.repository.Revision(ctx)
+	if err != nil {
+		c.logger.Error("Unable to get revision from database", "error", err)
+		return time.Time{}
+	}
+
+	return revision
+}
+
+// writePageHeaders writes the total count of coffees matching the query, and
+// the links to the first and next pages.
+func writePageHeaders(rw http.ResponseWriter, r *http.Request, query data.CoffeeQuery, page *data.CoffeePage) {
+	rw.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.Stale {
+		// The database failed, the page is the last successful result
+		rw.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
+
+	if query.Limit == 0 {
+		return
+	}
+
+	values := r.URL.Query()
+	values.Del("cursor")
+	links := []string{fmt.Sprintf(`<%s?%s>; rel="first"`, r.URL.Path, values.Encode())}
+
+	if page.NextCursor != "" {
+		values.Set("cursor", page.NextCursor)
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, values.Encode()))
+	}
+
+	rw.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// coffeeID parses the id route variable
+func coffeeID(r *http.Request) (int, error) {
+	return strconv.Atoi(mux.Vars(r)["id"])
+}
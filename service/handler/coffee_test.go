@@ -0,0 +1,411 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/service/problem"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// entityRepresentation writes the coffee entities as they are
+type entityRepresentation struct{}
+
+func (entityRepresentation) CoffeesJSON(ctx context.Context, coffees entities.Coffees) ([]byte, error) {
+	return json.Marshal(coffees)
+}
+
+func (entityRepresentation) CoffeeJSON(ctx context.Context, coffee *entities.Coffee) ([]byte, error) {
+	return json.Marshal(coffee)
+}
+
+var revision = time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func setupCoffeeHandler(t *testing.T) (*CoffeeService, *httptest.ResponseRecorder, *http.Request) {
+	c := &data.MockRepository{}
+	c.On("Find", mock.Anything, mock.MatchedBy(func(q data.CoffeeQuery) bool { return q.Limit == 0 })).Return(&data.CoffeePage{Coffees: entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, Total: 1}, nil)
+	c.On("Find", mock.Anything, mock.Anything).Return(&data.CoffeePage{Coffees: entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, Total: 2, NextCursor: "next"}, nil)
+	c.On("FindByID", mock.Anything, 1, mock.Anything).Return(&entities.Coffee{ID: 1, Name: "Test"}, nil)
+	c.On("FindByID", mock.Anything, 2, mock.Anything).Return(nil, data.ErrNotFound)
+	c.On("FindByID", mock.Anything, 3, mock.Anything).Return(nil, data.ErrUnavailable)
+	c.On("FindByID", mock.Anything, 4, true).Return(&entities.Coffee{ID: 4, Name: "Deleted", DeletedAt: sql.NullTime{Time: revision, Valid: true}}, nil)
+	c.On("FindByID", mock.Anything, 4, false).Return(nil, data.ErrNotFound)
+	c.On("FindIngredients", mock.Anything, 1).Return(entities.Ingredients{entities.Ingredient{ID: 1, Name: "Espresso", Quantity: 40, Unit: "ml"}}, nil)
+	c.On("FindIngredients", mock.Anything, 2).Return(nil, data.ErrNotFound)
+	c.On("CreateCoffee", mock.Anything, mock.Anything).Return(&entities.Coffee{ID: 7, Name: "Test"}, nil)
+	c.On("UpdateCoffee", mock.Anything, mock.MatchedBy(func(coffee entities.Coffee) bool { return coffee.ID == 1 })).Return(&entities.Coffee{ID: 1, Name: "Updated"}, nil)
+	c.On("UpdateCoffee", mock.Anything, mock.Anything).Return(nil, data.ErrNotFound)
+	c.On("DeleteCoffee", mock.Anything, 1).Return(nil)
+	c.On("DeleteCoffee", mock.Anything, 2).Return(data.ErrNotFound)
+	c.On("RestoreCoffee", mock.Anything, 1).Return(&entities.Coffee{ID: 1, Name: "Restored"}, nil)
+	c.On("RestoreCoffee", mock.Anything, 2).Return(nil, data.ErrNotFound)
+	c.On("RestoreCoffee", mock.Anything, 3).Return(nil, data.ErrConflict)
+	c.On("Revision", mock.Anything).Return(revision, nil)
+
+	l := hclog.Default()
+
+	return NewCoffeeService(c, entityRepresentation{}, l), httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil)
+}
+
+func TestCoffeesReturnsCoffees(t *testing.T) {
+	c, rw, r := setupCoffeeHandler(t)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffees{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+}
+
+func TestCoffeesReturnsTotalCount(t *testing.T) {
+	c, rw, r := setupCoffeeHandler(t)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, "1", rw.Header().Get("X-Total-Count"))
+	assert.Empty(t, rw.Header().Get("Link"))
+}
+
+func TestCoffeesReturnsPaginationLinks(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := httptest.NewRequest("GET", "/coffees?limit=1&sort=-price", nil)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "2", rw.Header().Get("X-Total-Count"))
+	assert.Equal(t, `</coffees?limit=1&sort=-price>; rel="first", </coffees?cursor=next&limit=1&sort=-price>; rel="next"`, rw.Header().Get("Link"))
+	c.repository.(*data.MockRepository).AssertCalled(t, "Find", mock.Anything, data.CoffeeQuery{Limit: 1, Sort: []data.Sort{{Field: data.SortByPrice, Descending: true}}})
+}
+
+func TestCoffeesReturnsBadRequestForInvalidQuery(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := httptest.NewRequest("GET", "/coffees?sort=teaser", nil)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestCoffeeReturnsCoffee(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/1", nil), map[string]string{"id": "1"})
+
+	c.GetCoffee(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffee{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bd.ID)
+}
+
+func TestCoffeeReturnsNotFoundWhenMissing(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/2", nil), map[string]string{"id": "2"})
+
+	c.GetCoffee(rw, r)
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, problem.ContentType, rw.Header().Get("Content-Type"))
+
+	bd := problem.Problem{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, problem.TypeNotFound, bd.Type)
+	assert.Equal(t, http.StatusNotFound, bd.Status)
+	assert.Equal(t, "Coffee not found", bd.Detail)
+	assert.Equal(t, "/coffees/2", bd.Instance)
+}
+
+func TestCoffeeReturnsDeletedCoffeeOnlyWhenIncluded(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/4", nil), map[string]string{"id": "4"})
+
+	c.GetCoffee(rw, r)
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	rw = httptest.NewRecorder()
+	r = mux.SetURLVars(httptest.NewRequest("GET", "/coffees/4?include_deleted=true", nil), map[string]string{"id": "4"})
+
+	c.GetCoffee(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffee{}
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &bd))
+	assert.Equal(t, "Deleted", bd.Name)
+}
+
+func TestCoffeeRejectsInvalidIncludeDeleted(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/4?include_deleted=maybe", nil), map[string]string{"id": "4"})
+
+	c.GetCoffee(rw, r)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestCoffeeReturnsServiceUnavailableWhenDatabaseIsUnavailable(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/3", nil), map[string]string{"id": "3"})
+	r.Header.Set(problem.RequestIDHeader, "abc123")
+
+	c.GetCoffee(rw, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+
+	bd := problem.Problem{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, problem.TypeUnavailable, bd.Type)
+	assert.Equal(t, "abc123", bd.RequestID)
+}
+
+func TestCoffeeIngredientsReturnsIngredients(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/1/ingredients", nil), map[string]string{"id": "1"})
+
+	c.GetCoffeeIngredients(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Ingredients{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Len(t, bd, 1)
+	assert.Equal(t, "Espresso", bd[0].Name)
+	assert.Equal(t, 40, bd[0].Quantity)
+	assert.Equal(t, "ml", bd[0].Unit)
+}
+
+func TestCoffeeIngredientsReturnsNotFoundWhenMissing(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/2/ingredients", nil), map[string]string{"id": "2"})
+
+	c.GetCoffeeIngredients(rw, r)
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestCreateCoffeeReturnsCreatedCoffee(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"name":"Test","price":100,"ingredients":[{"ingredient_id":1,"quantity":40,"unit":"ml"}]}`))
+
+	c.CreateCoffee(rw, r)
+
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "/coffees/7", rw.Header().Get("Location"))
+
+	bd := entities.Coffee{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, bd.ID)
+}
+
+func TestCreateCoffeeReturnsBadRequestWhenInvalid(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := httptest.NewRequest("POST", "/coffees", strings.NewReader(`{"price":100}`))
+
+	c.CreateCoffee(rw, r)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	c.repository.(*data.MockRepository).AssertNotCalled(t, "CreateCoffee", mock.Anything, mock.Anything)
+}
+
+func TestUpdateCoffeeReturnsUpdatedCoffee(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("PUT", "/coffees/1", strings.NewReader(`{"name":"Updated","price":100}`)), map[string]string{"id": "1"})
+
+	c.UpdateCoffee(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffee{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, "Updated", bd.Name)
+}
+
+func TestUpdateCoffeeReturnsNotFoundWhenMissing(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("PUT", "/coffees/2", strings.NewReader(`{"name":"Updated","price":100}`)), map[string]string{"id": "2"})
+
+	c.UpdateCoffee(rw, r)
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestPatchCoffeeMergesWithStoredCoffee(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("PATCH", "/coffees/1", strings.NewReader(`{"price":100}`)), map[string]string{"id": "1"})
+
+	c.PatchCoffee(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	c.repository.(*data.MockRepository).AssertCalled(t, "UpdateCoffee", mock.Anything, entities.Coffee{ID: 1, Name: "Test", Price: 100})
+}
+
+func TestDeleteCoffeeReturnsNoContent(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("DELETE", "/coffees/1", nil), map[string]string{"id": "1"})
+
+	c.DeleteCoffee(rw, r)
+
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+}
+
+func TestDeleteCoffeeReturnsNotFoundWhenMissing(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("DELETE", "/coffees/2", nil), map[string]string{"id": "2"})
+
+	c.DeleteCoffee(rw, r)
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestRestoreCoffeeReturnsRestoredCoffee(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("POST", "/coffees/1/restore", nil), map[string]string{"id": "1"})
+
+	c.RestoreCoffee(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Coffee{}
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &bd))
+	assert.Equal(t, "Restored", bd.Name)
+}
+
+func TestRestoreCoffeeReturnsNotFoundWhenPurged(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("POST", "/coffees/2/restore", nil), map[string]string{"id": "2"})
+
+	c.RestoreCoffee(rw, r)
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestRestoreCoffeeReturnsConflictForDeletedIngredient(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("POST", "/coffees/3/restore", nil), map[string]string{"id": "3"})
+
+	c.RestoreCoffee(rw, r)
+
+	assert.Equal(t, http.StatusConflict, rw.Code)
+	assert.Contains(t, rw.Body.String(), "Coffee uses a deleted ingredient")
+}
+
+func TestCoffeesWarnsWhenPageIsStale(t *testing.T) {
+	c, rw, r := setupCoffeeHandler(t)
+	repository := &data.MockRepository{}
+	repository.On("Find", mock.Anything, mock.Anything).Return(&data.CoffeePage{Coffees: entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, Total: 1, Stale: true}, nil)
+	repository.On("Revision", mock.Anything).Return(revision, nil)
+	c.repository = repository
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, `110 - "Response is Stale"`, rw.Header().Get("Warning"))
+}
+
+func TestCoffeesAnswersIfNoneMatchWithNotModified(t *testing.T) {
+	c, rw, r := setupCoffeeHandler(t)
+	c.ServeHTTP(rw, r)
+	etag := rw.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rw = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set("If-None-Match", etag)
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+	assert.Empty(t, rw.Body.Bytes())
+	assert.Equal(t, etag, rw.Header().Get("ETag"))
+	assert.Equal(t, "1", rw.Header().Get("X-Total-Count"))
+}
+
+func TestCoffeesAnswersIfModifiedSinceWithNotModified(t *testing.T) {
+	c, rw, r := setupCoffeeHandler(t)
+	r.Header.Set("If-Modified-Since", "Mon, 01 Mar 2021 12:00:00 GMT")
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+	assert.Equal(t, "Mon, 01 Mar 2021 12:00:00 GMT", rw.Header().Get("Last-Modified"))
+}
+
+func TestCoffeesIncludingDeletedLeaveOutLastModified(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := httptest.NewRequest("GET", "/coffees?include_deleted=true", nil)
+	r.Header.Set("If-Modified-Since", "Mon, 01 Mar 2021 12:00:00 GMT")
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Empty(t, rw.Header().Get("Last-Modified"))
+}
+
+func TestCoffeesLeaveOutLastModifiedWhenRevisionFails(t *testing.T) {
+	c, rw, r := setupCoffeeHandler(t)
+	repository := &data.MockRepository{}
+	repository.On("Find", mock.Anything, mock.Anything).Return(&data.CoffeePage{Coffees: entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, Total: 1}, nil)
+	repository.On("Revision", mock.Anything).Return(nil, data.ErrUnavailable)
+	c.repository = repository
+
+	c.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.NotEmpty(t, rw.Header().Get("ETag"))
+	assert.Empty(t, rw.Header().Get("Last-Modified"))
+}
+
+func TestCoffeeIngredientsAnswerIfNoneMatchWithNotModified(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/1/ingredients", nil), map[string]string{"id": "1"})
+	c.GetCoffeeIngredients(rw, r)
+	etag := rw.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "Mon, 01 Mar 2021 12:00:00 GMT", rw.Header().Get("Last-Modified"))
+
+	rw = httptest.NewRecorder()
+	r.Header.Set("If-None-Match", etag)
+
+	c.GetCoffeeIngredients(rw, r)
+
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+	assert.Empty(t, rw.Body.Bytes())
+}
+
+func TestCoffeeAnswersIfNoneMatchWithNotModified(t *testing.T) {
+	c, rw, _ := setupCoffeeHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/coffees/1", nil), map[string]string{"id": "1"})
+	c.GetCoffee(rw, r)
+	etag := rw.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rw = httptest.NewRecorder()
+	r.Header.Set("If-None-Match", etag)
+
+	c.GetCoffee(rw, r)
+
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+	assert.Empty(t, rw.Body.Bytes())
+}
@@ -1,25 +1,141 @@
 package service
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 )
 
 // TODO: Move this to hckit.
 
+const (
+	// StatusOK reports a healthy service or dependency
+	StatusOK = "ok"
+	// StatusUnavailable reports an unhealthy service or dependency
+	StatusUnavailable = "unavailable"
+	// StatusShuttingDown reports a service draining its requests before it
+	// stops
+	StatusShuttingDown = "shutting_down"
+)
+
+// Pinger is a dependency of the service probed by the readiness check, e.g.
+// the data.Repository.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Dependency names a dependency probed by the readiness check
+type Dependency struct {
+	Name   string
+	Pinger Pinger
+	// State optionally reports the state of the client of the dependency, e.g.
+	// its circuit breaker
+	State func() string
+}
+
+// Health is the response of the health checks
+type Health struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies,omitempty"`
+}
+
+// DependencyHealth is the result of the probe of a dependency
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	State     string  `json:"state,omitempty"`
+}
+
 // HealthService is an HTTP Handler for health checking
 type HealthService struct {
-	logger hclog.Logger
+	logger       hclog.Logger
+	timeout      time.Duration
+	dependencies []Dependency
+	// shuttingDown is set to 1 once the service starts to shut down
+	shuttingDown int32
 }
 
-// NewHealth creates a new Health handler
-func NewHealth(l hclog.Logger) *HealthService {
-	return &HealthService{l}
+// NewHealth creates a new Health handler probing the dependencies for
+// readiness, each within the timeout.
+func NewHealth(l hclog.Logger, timeout time.Duration, dependencies ...Dependency) *HealthService {
+	return &HealthService{logger: l, timeout: timeout, dependencies: dependencies}
 }
 
-// ServeHTTP implements the handler interface
+// ShutDown fails the readiness check from now on, so that no new requests are
+// routed to the service while it drains the requests in flight.
+func (h *HealthService) ShutDown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+// ServeHTTP implements the handler interface, it reports liveness so that the
+// probes configured before the readiness check keep working.
 func (h *HealthService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(rw, "%s", "ok")
+	h.Live(rw, r)
+}
+
+// Live reports the process is able to serve requests. The dependencies are not
+// probed, so that an unavailable database does not restart the service.
+func (h *HealthService) Live(rw http.ResponseWriter, r *http.Request) {
+	writeHealth(rw, http.StatusOK, Health{Status: StatusOK})
+}
+
+// Ready probes every dependency and reports whether the service should receive
+// traffic, with 503 Service Unavailable when any dependency is unhealthy.
+func (h *HealthService) Ready(rw http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&h.shuttingDown) == 1 {
+		writeHealth(rw, http.StatusServiceUnavailable, Health{Status: StatusShuttingDown})
+		return
+	}
+
+	health := Health{Status: StatusOK, Dependencies: map[string]DependencyHealth{}}
+
+	for _, dependency := range h.dependencies {
+		result := h.probe(r.Context(), dependency.Pinger)
+		if dependency.State != nil {
+			result.State = dependency.State()
+		}
+		if result.Status != StatusOK {
+			h.logger.Error("Dependency is unhealthy", "dependency", dependency.Name, "error", result.Error)
+			health.Status = StatusUnavailable
+		}
+
+		health.Dependencies[dependency.Name] = result
+	}
+
+	status := http.StatusOK
+	if health.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeHealth(rw, status, health)
+}
+
+// probe pings the dependency within the timeout
+func (h *HealthService) probe(ctx context.Context, pinger Pinger) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := pinger.Ping(ctx)
+	result := DependencyHealth{Status: StatusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func writeHealth(rw http.ResponseWriter, status int, health Health) {
+	rw.Header().Set("Content-Type", "application/json")
+	// Probes must never see a cached result
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(health)
 }
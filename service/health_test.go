@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// slowPinger answers once the context is done
+type slowPinger struct{}
+
+func (slowPinger) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func setupHealth(t *testing.T, err error) *HealthService {
+	repository := &data.MockRepository{}
+	repository.On("Ping", mock.Anything).Return(err)
+
+	return NewHealth(hclog.NewNullLogger(), 50*time.Millisecond, Dependency{Name: "database", Pinger: repository})
+}
+
+func decodeHealth(t *testing.T, rw *httptest.ResponseRecorder) Health {
+	health := Health{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &health))
+
+	return health
+}
+
+func TestLiveDoesNotProbeDependencies(t *testing.T) {
+	h := setupHealth(t, errors.New("connection refused"))
+	rw := httptest.NewRecorder()
+
+	h.Live(rw, httptest.NewRequest("GET", "/health/live", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, StatusOK, decodeHealth(t, rw).Status)
+}
+
+func TestReadyReportsHealthyDependencies(t *testing.T) {
+	h := setupHealth(t, nil)
+	rw := httptest.NewRecorder()
+
+	h.Ready(rw, httptest.NewRequest("GET", "/health/ready", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	health := decodeHealth(t, rw)
+	assert.Equal(t, StatusOK, health.Status)
+	assert.Equal(t, StatusOK, health.Dependencies["database"].Status)
+}
+
+func TestReadyReturnsServiceUnavailableWhenDependencyFails(t *testing.T) {
+	h := setupHealth(t, errors.New("connection refused"))
+	rw := httptest.NewRecorder()
+
+	h.Ready(rw, httptest.NewRequest("GET", "/health/ready", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	health := decodeHealth(t, rw)
+	assert.Equal(t, StatusUnavailable, health.Status)
+	assert.Equal(t, "connection refused", health.Dependencies["database"].Error)
+}
+
+func TestReadyTimesOutSlowDependencies(t *testing.T) {
+	h := NewHealth(hclog.NewNullLogger(), 10*time.Millisecond, Dependency{Name: "database", Pinger: slowPinger{}})
+	rw := httptest.NewRecorder()
+
+	h.Ready(rw, httptest.NewRequest("GET", "/health/ready", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), decodeHealth(t, rw).Dependencies["database"].Error)
+}
+
+func TestReadyFailsOnceShuttingDown(t *testing.T) {
+	h := setupHealth(t, nil)
+	rw := httptest.NewRecorder()
+
+	h.ShutDown()
+	h.Ready(rw, httptest.NewRequest("GET", "/health/ready", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Equal(t, StatusShuttingDown, decodeHealth(t, rw).Status)
+}
+
+func TestReadyReportsDependencyState(t *testing.T) {
+	repository := &data.MockRepository{}
+	repository.On("Ping", mock.Anything).Return(nil)
+	h := NewHealth(hclog.NewNullLogger(), 50*time.Millisecond, Dependency{Name: "database", Pinger: repository, State: func() string { return "half-open" }})
+	rw := httptest.NewRecorder()
+
+	h.Ready(rw, httptest.NewRequest("GET", "/health/ready", nil))
+
+	assert.Equal(t, "half-open", decodeHealth(t, rw).Dependencies["database"].State)
+}
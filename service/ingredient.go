@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/service/conditional"
+	"github.com/hashicorp-demoapp/coffee-service/service/problem"
+)
+
+// IngredientService is an HTTP Handler for the ingredient catalog. The catalog
+// is maintained independently of the coffee api version.
+type IngredientService struct {
+	repository data.Repository
+	logger     hclog.Logger
+}
+
+// NewIngredient creates a new Ingredient handler
+func NewIngredient(repository data.Repository, l hclog.Logger) *IngredientService {
+	return &IngredientService{repository, l}
+}
+
+// RegisterIngredientRoutes registers the routes of the ingredient catalog on
+// the router, the GET responses carry the cacheControl header.
+func RegisterIngredientRoutes(router *mux.Router, ingredientService *IngredientService, cacheControl string) {
+	cache := CacheControl(cacheControl)
+	router.Handle("/ingredients", cache(ingredientService)).Methods("GET")
+	router.Handle("/ingredients/{id:[0-9]+}", cache(http.HandlerFunc(ingredientService.GetIngredient))).Methods("GET")
+	router.HandleFunc("/ingredients", ingredientService.CreateIngredient).Methods("POST")
+	router.HandleFunc("/ingredients/{id:[0-9]+}", ingredientService.UpdateIngredient).Methods("PUT")
+	router.HandleFunc("/ingredients/{id:[0-9]+}", ingredientService.DeleteIngredient).Methods("DELETE")
+}
+
+// ServeHTTP handles incoming requests for the api ingredients route. The query
+// string supports the include_deleted and updated_since parameters of
+// data.IngredientQuery. Clients revalidate the catalog with If-None-Match or,
+// when the deleted ingredients are left out, If-Modified-Since.
+func (i *IngredientService) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	i.logger.Debug("Handle Ingredients")
+
+	query, err := data.ParseIngredientQuery(r.URL.Query())
+	if err != nil {
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	ingredients, err := i.repository.ListIngredients(r.Context(), query)
+	if err != nil {
+		i.logger.Error("Unable to get ingredients from database", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+	i.logger.Debug(fmt.Sprintf("Found %d ingredients", len(ingredients)))
+
+	ingredientsJSON, err := ingredients.ToJSON()
+	if err != nil {
+		i.logger.Error("Unable to convert ingredients to JSON", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	// Purges and filters remove ingredients without a trace in their updates,
+	// only the revision of the repository dates the catalog
+	var modified time.Time
+	if !query.IncludeDeleted {
+		if modified, err = i.repository.Revision(r.Context()); err != nil {
+			i.logger.Error("Unable to get revision from database", "error", err)
+			modified = time.Time{}
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	conditional.Write(rw, r, ingredientsJSON, modified)
+}
+
+// GetIngredient handles incoming requests for the api ingredients/{id} route.
+// A deleted ingredient is only returned with include_deleted=true.
+func (i *IngredientService) GetIngredient(rw http.ResponseWriter, r *http.Request) {
+	i.logger.Debug("Handle Ingredient")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid ingredient id"))
+		return
+	}
+
+	includeDeleted, err := data.ParseIncludeDeleted(r.URL.Query())
+	if err != nil {
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	ingredient, err := i.repository.FindIngredientByID(r.Context(), id, includeDeleted)
+	if i.handleError(rw, r, err) {
+		return
+	}
+
+	i.writeIngredient(rw, r, http.StatusOK, ingredient)
+}
+
+// CreateIngredient handles POST requests for the api ingredients route
+func (i *IngredientService) CreateIngredient(rw http.ResponseWriter, r *http.Request) {
+	i.logger.Debug("Handle Create Ingredient")
+
+	ingredient := entities.Ingredient{}
+	if err := ingredient.FromJSON(r.Body); err != nil {
+		i.logger.Debug("Unable to parse ingredient", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Unable to parse ingredient"))
+		return
+	}
+	ingredient.ID = 0
+
+	if err := ingredient.Validate(); err != nil {
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	created, err := i.repository.CreateIngredient(r.Context(), ingredient)
+	if i.handleError(rw, r, err) {
+		return
+	}
+	i.logger.Debug(fmt.Sprintf("Created ingredient %d", created.ID))
+
+	rw.Header().Set("Location", fmt.Sprintf("/ingredients/%d", created.ID))
+	i.writeIngredient(rw, r, http.StatusCreated, created)
+}
+
+// UpdateIngredient handles PUT requests for the api ingredients/{id} route
+func (i *IngredientService) UpdateIngredient(rw http.ResponseWriter, r *http.Request) {
+	i.logger.Debug("Handle Update Ingredient")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid ingredient id"))
+		return
+	}
+
+	ingredient := entities.Ingredient{}
+	if err := ingredient.FromJSON(r.Body); err != nil {
+		i.logger.Debug("Unable to parse ingredient", "error", err)
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Unable to parse ingredient"))
+		return
+	}
+	ingredient.ID = id
+
+	if err := ingredient.Validate(); err != nil {
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	updated, err := i.repository.UpdateIngredient(r.Context(), ingredient)
+	if i.handleError(rw, r, err) {
+		return
+	}
+	i.logger.Debug(fmt.Sprintf("Updated ingredient %d", updated.ID))
+
+	i.writeIngredient(rw, r, http.StatusOK, updated)
+}
+
+// DeleteIngredient handles DELETE requests for the api ingredients/{id} route.
+// Ingredients still used by a coffee can not be deleted.
+func (i *IngredientService) DeleteIngredient(rw http.ResponseWriter, r *http.Request) {
+	i.logger.Debug("Handle Delete Ingredient")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		problem.Write(rw, r, problem.New(http.StatusBadRequest, "Invalid ingredient id"))
+		return
+	}
+
+	err = i.repository.DeleteIngredient(r.Context(), id)
+	if i.handleError(rw, r, err) {
+		return
+	}
+	i.logger.Debug(fmt.Sprintf("Deleted ingredient %d", id))
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// handleError writes the response for an error returned by the repository,
+// and reports whether the request has been handled.
+func (i *IngredientService) handleError(rw http.ResponseWriter, r *http.Request, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if err == data.ErrNotFound {
+		problem.Write(rw, r, problem.NotFound("Ingredient not found"))
+		return true
+	}
+
+	if err == data.ErrConflict {
+		p := problem.FromError(err)
+		p.Detail = "Ingredient is used by a coffee"
+		problem.Write(rw, r, p)
+		return true
+	}
+
+	if verr, ok := err.(*entities.ValidationError); ok {
+		problem.WriteError(rw, r, verr)
+		return true
+	}
+
+	i.logger.Error("Unable to access ingredients in database", "error", err)
+	problem.WriteError(rw, r, err)
+	return true
+}
+
+func (i *IngredientService) writeIngredient(rw http.ResponseWriter, r *http.Request, status int, ingredient *entities.Ingredient) {
+	ingredientJSON, err := ingredient.ToJSON()
+	if err != nil {
+		i.logger.Error("Unable to convert ingredient to JSON", "error", err)
+		problem.WriteError(rw, r, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if status == http.StatusOK {
+		// The ingredient is the current representation, it carries its validators
+		conditional.Write(rw, r, ingredientJSON, ingredient.UpdatedAt)
+		return
+	}
+
+	rw.WriteHeader(status)
+	rw.Write(ingredientJSON)
+}
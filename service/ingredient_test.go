@@ -0,0 +1,172 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupIngredientHandler(t *testing.T) (*IngredientService, *httptest.ResponseRecorder) {
+	c := &data.MockRepository{}
+	c.On("ListIngredients", mock.Anything, mock.Anything).Return(entities.Ingredients{entities.Ingredient{ID: 1, Name: "Espresso"}}, nil)
+	c.On("FindIngredientByID", mock.Anything, 1, false).Return(&entities.Ingredient{ID: 1, Name: "Espresso"}, nil)
+	c.On("FindIngredientByID", mock.Anything, 2, false).Return(nil, data.ErrNotFound)
+	c.On("FindIngredientByID", mock.Anything, 2, true).Return(&entities.Ingredient{ID: 2, Name: "Steamed Milk", DeletedAt: sql.NullTime{Time: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), Valid: true}}, nil)
+	c.On("CreateIngredient", mock.Anything, mock.Anything).Return(&entities.Ingredient{ID: 6, Name: "Oat Milk"}, nil)
+	c.On("UpdateIngredient", mock.Anything, mock.Anything).Return(&entities.Ingredient{ID: 1, Name: "Double Espresso"}, nil)
+	c.On("DeleteIngredient", mock.Anything, 1).Return(data.ErrConflict)
+	c.On("DeleteIngredient", mock.Anything, 3).Return(nil)
+	c.On("Revision", mock.Anything).Return(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), nil)
+
+	return NewIngredient(c, hclog.Default()), httptest.NewRecorder()
+}
+
+func TestIngredientsReturnsIngredients(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+
+	i.ServeHTTP(rw, httptest.NewRequest("GET", "/ingredients", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Ingredients{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Len(t, bd, 1)
+}
+
+func TestIngredientsIncludesDeletedIngredients(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+
+	i.ServeHTTP(rw, httptest.NewRequest("GET", "/ingredients?include_deleted=true", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	i.repository.(*data.MockRepository).AssertCalled(t, "ListIngredients", mock.Anything, data.IngredientQuery{IncludeDeleted: true})
+}
+
+func TestIngredientsReturnsBadRequestForInvalidQuery(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+
+	i.ServeHTTP(rw, httptest.NewRequest("GET", "/ingredients?include_deleted=maybe", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestIngredientsAnswersIfNoneMatchWithNotModified(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	i.ServeHTTP(rw, httptest.NewRequest("GET", "/ingredients", nil))
+	etag := rw.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rw = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/ingredients", nil)
+	r.Header.Set("If-None-Match", etag)
+
+	i.ServeHTTP(rw, r)
+
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+	assert.Empty(t, rw.Body.Bytes())
+}
+
+func TestIngredientReturnsIngredient(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/ingredients/1", nil), map[string]string{"id": "1"})
+
+	i.GetIngredient(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := entities.Ingredient{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, "Espresso", bd.Name)
+}
+
+func TestIngredientReturnsNotFoundWhenMissing(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/ingredients/2", nil), map[string]string{"id": "2"})
+
+	i.GetIngredient(rw, r)
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestIngredientReturnsDeletedIngredientWithIncludeDeleted(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/ingredients/2?include_deleted=true", nil), map[string]string{"id": "2"})
+
+	i.GetIngredient(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	bd := map[string]interface{}{}
+	err := json.Unmarshal(rw.Body.Bytes(), &bd)
+	assert.NoError(t, err)
+	assert.Equal(t, "2021-03-01T12:00:00Z", bd["deleted_at"])
+}
+
+func TestIngredientReturnsBadRequestForInvalidIncludeDeleted(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/ingredients/1?include_deleted=maybe", nil), map[string]string{"id": "1"})
+
+	i.GetIngredient(rw, r)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestCreateIngredientReturnsCreatedIngredient(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := httptest.NewRequest("POST", "/ingredients", strings.NewReader(`{"name":"Oat Milk"}`))
+
+	i.CreateIngredient(rw, r)
+
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "/ingredients/6", rw.Header().Get("Location"))
+}
+
+func TestCreateIngredientReturnsBadRequestWhenInvalid(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := httptest.NewRequest("POST", "/ingredients", strings.NewReader(`{"name":""}`))
+
+	i.CreateIngredient(rw, r)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestUpdateIngredientReturnsUpdatedIngredient(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("PUT", "/ingredients/1", strings.NewReader(`{"name":"Double Espresso"}`)), map[string]string{"id": "1"})
+
+	i.UpdateIngredient(rw, r)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	i.repository.(*data.MockRepository).AssertCalled(t, "UpdateIngredient", mock.Anything, entities.Ingredient{ID: 1, Name: "Double Espresso"})
+}
+
+func TestDeleteIngredientReturnsConflictWhenUsed(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("DELETE", "/ingredients/1", nil), map[string]string{"id": "1"})
+
+	i.DeleteIngredient(rw, r)
+
+	assert.Equal(t, http.StatusConflict, rw.Code)
+}
+
+func TestDeleteIngredientReturnsNoContent(t *testing.T) {
+	i, rw := setupIngredientHandler(t)
+	r := mux.SetURLVars(httptest.NewRequest("DELETE", "/ingredients/3", nil), map[string]string{"id": "3"})
+
+	i.DeleteIngredient(rw, r)
+
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+}
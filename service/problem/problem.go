@@ -0,0 +1,146 @@
+// Package problem writes the error responses of the api as RFC 7807 problem
+// details, with the application/problem+json content type.
+package problem
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// RequestIDHeader is the header carrying the id of the request, reported in
+// every problem so errors can be matched with the logs.
+const RequestIDHeader = "X-Request-ID"
+
+// Problem types identify the kind of error independently of the detail
+const (
+	// TypeBlank is used when the status code describes the problem
+	TypeBlank = "about:blank"
+	// TypeValidation is used when the request is invalid
+	TypeValidation = "urn:coffee-service:problem:validation"
+	// TypeNotFound is used when the requested resource does not exist
+	TypeNotFound = "urn:coffee-service:problem:not-found"
+	// TypeConflict is used when a command would break a reference
+	TypeConflict = "urn:coffee-service:problem:conflict"
+	// TypeUnavailable is used when the database cannot serve the request
+	TypeUnavailable = "urn:coffee-service:problem:unavailable"
+)
+
+// Problem is the body of an error response as defined by RFC 7807
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	RequestID     string         `json:"request_id,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// InvalidParam describes a field of the request that failed validation
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// New returns a Problem for the status code, titled with its status text
+func New(status int, detail string) *Problem {
+	return &Problem{
+		Type:   TypeBlank,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// NotFound returns a Problem for a resource that does not exist
+func NotFound(detail string) *Problem {
+	p := New(http.StatusNotFound, detail)
+	p.Type = TypeNotFound
+	return p
+}
+
+// Validation returns a Problem for a request that failed validation
+func Validation(err *entities.ValidationError) *Problem {
+	p := New(http.StatusBadRequest, err.Error())
+	p.Type = TypeValidation
+	p.InvalidParams = []InvalidParam{{Name: err.Field, Reason: err.Reason}}
+	return p
+}
+
+// FromError maps an error returned by the repository to a Problem. The details
+// of internal errors are not exposed, they are expected to be logged by the
+// caller.
+func FromError(err error) *Problem {
+	var verr *entities.ValidationError
+	if errors.As(err, &verr) {
+		return Validation(verr)
+	}
+
+	if errors.Is(err, data.ErrNotFound) {
+		return NotFound("The requested resource does not exist")
+	}
+
+	if errors.Is(err, data.ErrConflict) {
+		p := New(http.StatusConflict, "The resource is still referenced by another resource")
+		p.Type = TypeConflict
+		return p
+	}
+
+	if unavailable(err) {
+		p := New(http.StatusServiceUnavailable, "The database is unavailable, retry later")
+		p.Type = TypeUnavailable
+		return p
+	}
+
+	return New(http.StatusInternalServerError, "An unexpected error occurred")
+}
+
+// unavailable reports whether the error is caused by the database not being
+// reachable in time, rather than by the request.
+func unavailable(err error) bool {
+	if errors.Is(err, data.ErrUnavailable) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// Write writes the problem as the response to r. The caller must not write to
+// rw afterwards.
+func Write(rw http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = r.Header.Get(RequestIDHeader)
+	}
+
+	rw.Header().Set("Content-Type", ContentType)
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(p.Status)
+	json.NewEncoder(rw).Encode(p)
+}
+
+// WriteError writes the Problem mapped from err as the response to r. Clients
+// are told when to retry while the circuit breaker of the repository is open.
+func WriteError(rw http.ResponseWriter, r *http.Request, err error) {
+	var open *data.CircuitOpenError
+	if errors.As(err, &open) {
+		rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(open.RetryAfter.Seconds()))))
+	}
+
+	Write(rw, r, FromError(err))
+}
@@ -0,0 +1,85 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromErrorMapsRepositoryErrors(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		kind   string
+	}{
+		{&entities.ValidationError{Field: "name", Reason: "is required"}, http.StatusBadRequest, TypeValidation},
+		{data.ErrNotFound, http.StatusNotFound, TypeNotFound},
+		{fmt.Errorf("deleting ingredient: %w", data.ErrConflict), http.StatusConflict, TypeConflict},
+		{data.ErrUnavailable, http.StatusServiceUnavailable, TypeUnavailable},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable, TypeUnavailable},
+		{&data.CircuitOpenError{RetryAfter: time.Second}, http.StatusServiceUnavailable, TypeUnavailable},
+		{errors.New("pq: syntax error"), http.StatusInternalServerError, TypeBlank},
+	}
+
+	for _, c := range cases {
+		p := FromError(c.err)
+
+		assert.Equal(t, c.status, p.Status, c.err.Error())
+		assert.Equal(t, c.kind, p.Type, c.err.Error())
+		assert.Equal(t, http.StatusText(c.status), p.Title, c.err.Error())
+	}
+}
+
+func TestFromErrorDoesNotExposeInternalErrors(t *testing.T) {
+	p := FromError(errors.New("pq: password authentication failed for user postgres"))
+
+	assert.NotContains(t, p.Detail, "postgres")
+}
+
+func TestFromErrorReportsInvalidParams(t *testing.T) {
+	p := FromError(&entities.ValidationError{Field: "price", Reason: "must not be negative"})
+
+	assert.Equal(t, "price must not be negative", p.Detail)
+	assert.Equal(t, []InvalidParam{{Name: "price", Reason: "must not be negative"}}, p.InvalidParams)
+}
+
+func TestWriteWritesProblemJSON(t *testing.T) {
+	rw := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/coffees/99", nil)
+	r.Header.Set(RequestIDHeader, "abc123")
+
+	Write(rw, r, NotFound("Coffee not found"))
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, ContentType, rw.Header().Get("Content-Type"))
+
+	bd := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &bd))
+	assert.Equal(t, map[string]interface{}{
+		"type":       TypeNotFound,
+		"title":      "Not Found",
+		"status":     float64(http.StatusNotFound),
+		"detail":     "Coffee not found",
+		"instance":   "/coffees/99",
+		"request_id": "abc123",
+	}, bd)
+}
+
+func TestWriteErrorSetsRetryAfterWhileCircuitIsOpen(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	WriteError(rw, httptest.NewRequest("GET", "/coffees", nil), &data.CircuitOpenError{RetryAfter: 2500 * time.Millisecond})
+
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Equal(t, "3", rw.Header().Get("Retry-After"))
+}
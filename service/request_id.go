@@ -0,0 +1,39 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/hashicorp-demoapp/coffee-service/service/problem"
+)
+
+// validRequestID matches the ids set by a client or a proxy that are kept. They
+// are written to the logs and the responses, so they are short and printable.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID is middleware giving every request an id, reported in the
+// X-Request-ID response header and in problem responses. A valid id set by
+// the client or a proxy is kept, any other is replaced.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(problem.RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+			r.Header.Set(problem.RequestIDHeader, id)
+		}
+
+		rw.Header().Set(problem.RequestIDHeader, id)
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// newRequestID returns a random 128 bit id
+func newRequestID() string {
+	id := make([]byte, 16)
+	// crypto/rand does not fail on the supported platforms
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
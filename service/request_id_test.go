@@ -0,0 +1,67 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp-demoapp/coffee-service/service/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDGeneratesID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get(problem.RequestIDHeader)
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+
+	assert.Len(t, seen, 32)
+	assert.Equal(t, seen, rw.Header().Get(problem.RequestIDHeader))
+}
+
+func TestRequestIDKeepsClientID(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		problem.Write(rw, r, problem.New(http.StatusTeapot, ""))
+	}))
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set(problem.RequestIDHeader, "abc123")
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+
+	assert.Equal(t, "abc123", rw.Header().Get(problem.RequestIDHeader))
+	assert.Contains(t, rw.Body.String(), `"request_id":"abc123"`)
+}
+
+func TestRequestIDReplacesInvalidClientID(t *testing.T) {
+	for _, id := range []string{"abc 123", "abc\"}, {\"injected\":\"", strings.Repeat("a", 129)} {
+		var seen string
+		handler := RequestID(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			seen = r.Header.Get(problem.RequestIDHeader)
+		}))
+		r := httptest.NewRequest("GET", "/coffees", nil)
+		r.Header.Set(problem.RequestIDHeader, id)
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+
+		assert.Len(t, seen, 32, id)
+		assert.Equal(t, seen, rw.Header().Get(problem.RequestIDHeader), id)
+	}
+}
+
+func TestRequestIDKeepsLongestValidClientID(t *testing.T) {
+	id := strings.Repeat("a-1.B_", 21) + "xy"
+	handler := RequestID(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest("GET", "/coffees", nil)
+	r.Header.Set(problem.RequestIDHeader, id)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+
+	assert.Equal(t, id, rw.Header().Get(problem.RequestIDHeader))
+}
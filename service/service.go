@@ -20,37 +20,37 @@ type CoffeeService struct {
 	logger     hclog.Logger
 }
 
+// CoffeeAPI is the set of handlers each version of the coffee api implements.
+// ServeHTTP handles the coffees collection route.
+type CoffeeAPI interface {
+	http.Handler
+	GetCoffee(rw http.ResponseWriter, r *http.Request)
+	GetCoffeeIngredients(rw http.ResponseWriter, r *http.Request)
+	CreateCoffee(rw http.ResponseWriter, r *http.Request)
+	UpdateCoffee(rw http.ResponseWriter, r *http.Request)
+	PatchCoffee(rw http.ResponseWriter, r *http.Request)
+	DeleteCoffee(rw http.ResponseWriter, r *http.Request)
+	RestoreCoffee(rw http.ResponseWriter, r *http.Request)
+}
+
 // NewCoffee is a factory method that returns a configured handler for the
-// configured ServiceVersion
-func NewCoffee(cfg *config.Config) (http.Handler, error) {
-	var repository data.Repository
-	var err error
-
-	cfg.Logger.Debug(fmt.Sprintf("Resolving repository for version %v", cfg.Version))
-	if cfg.Version == config.V1 || cfg.Version == config.V2 {
-		cfg.Logger.Debug("Loading Postgres")
-		if repository, err = data.NewFromConfig(cfg); err != nil {
-			cfg.Logger.Debug(fmt.Sprintf("Error loading postgres %+v", err))
-			return nil, err
-		}
-	} else if cfg.Version == config.V3 {
-		cfg.Logger.Debug("Loading in memory db")
-		if repository, err = data.NewInMemoryDB(cfg); err != nil {
-			cfg.Logger.Debug(fmt.Sprintf("Error loading in memory db %+v", err))
-			return nil, err
-		}
+// configured ServiceVersion. The handler serves the coffees from the given
+// repository, whichever backend it uses. An error is returned when the
+// version is unknown.
+func NewCoffee(cfg *config.Config, repository data.Repository) (CoffeeAPI, error) {
+	cfg.Logger.Debug(fmt.Sprintf("Resolving service for version %v", cfg.Version))
+	if repository == nil {
+		return nil, fmt.Errorf("no repository for service version %s", cfg.Version)
 	}
 
-	cfg.Logger.Debug(fmt.Sprintf("Resolving service for version %v", cfg.Version))
-	var handler http.Handler
 	switch cfg.Version {
 	case config.V1:
-		handler = v1.NewCoffeeService(repository, cfg.Logger)
+		return v1.NewCoffeeService(repository, cfg.Logger), nil
 	case config.V2:
-		handler = v2.NewCoffeeService(repository, cfg.Logger)
+		return v2.NewCoffeeService(repository, cfg.Logger), nil
 	case config.V3:
-		handler = v3.NewCoffeeService(repository, cfg.Logger)
+		return v3.NewCoffeeService(repository, cfg.Logger), nil
 	}
 
-	return handler, nil
+	return nil, fmt.Errorf("unknown service version %q, expected one of %s, %s or %s", cfg.Version, config.V1, config.V2, config.V3)
 }
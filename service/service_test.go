@@ -0,0 +1,67 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCoffeeServesAnyBackendForEveryVersion(t *testing.T) {
+	for _, backend := range []config.BackendKey{config.Memory, config.SQLite} {
+		cfg := &config.Config{Logger: hclog.NewNullLogger(), DBBackend: backend, SQLitePath: t.TempDir() + "/coffee-service.db"}
+
+		repository, err := data.NewRepository(cfg)
+		require.NoError(t, err, backend.String())
+
+		for _, version := range []config.VersionKey{config.V1, config.V2, config.V3} {
+			cfg.Version = version
+
+			handler, err := NewCoffee(cfg, repository)
+			assert.NoError(t, err, "%s on %s", version, backend)
+			assert.NotNil(t, handler, "%s on %s", version, backend)
+		}
+	}
+}
+
+func TestNewCoffeeUsesTheVersionRepresentation(t *testing.T) {
+	repository := &data.MockRepository{}
+	repository.On("Find", mock.Anything, mock.Anything).Return(&data.CoffeePage{Coffees: entities.Coffees{{ID: 1, Name: "Test"}}, Total: 1}, nil)
+	repository.On("Revision", mock.Anything).Return(time.Time{}, nil)
+
+	// Only v3 reports whether a coffee is available
+	for version, available := range map[config.VersionKey]bool{config.V1: false, config.V3: true} {
+		handler, err := NewCoffee(&config.Config{Logger: hclog.NewNullLogger(), Version: version}, repository)
+		require.NoError(t, err)
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+
+		coffees := []map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &coffees), version.String())
+		require.Len(t, coffees, 1, version.String())
+		assert.Equal(t, available, coffees[0]["available"] != nil, version.String())
+	}
+}
+
+func TestNewCoffeeReturnsErrorForUnknownVersion(t *testing.T) {
+	handler, err := NewCoffee(&config.Config{Logger: hclog.NewNullLogger(), Version: config.VUnknown}, &data.MockRepository{})
+
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
+
+func TestNewCoffeeReturnsErrorWithoutRepository(t *testing.T) {
+	handler, err := NewCoffee(&config.Config{Logger: hclog.NewNullLogger(), Version: config.V1}, nil)
+
+	assert.Error(t, err)
+	assert.Nil(t, handler)
+}
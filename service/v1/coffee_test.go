@@ -5,20 +5,24 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/hashicorp-demoapp/coffee-service/data"
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/service/handler"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
-func setupCoffeeHandler(t *testing.T) (*CoffeeService, *httptest.ResponseRecorder, *http.Request) {
+func setupCoffeeHandler(t *testing.T) (*handler.CoffeeService, *httptest.ResponseRecorder, *http.Request) {
 	c := &data.MockRepository{}
-	c.On("Find").Return(entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, nil)
+	c.On("Find", mock.Anything, mock.Anything).Return(&data.CoffeePage{Coffees: entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, Total: 1}, nil)
+	c.On("Revision", mock.Anything).Return(time.Time{}, nil)
 
 	l := hclog.Default()
 
-	return &CoffeeService{c, l}, httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil)
+	return NewCoffeeService(c, l), httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil)
 }
 
 func TestCoffeesReturnsCoffees(t *testing.T) {
@@ -28,7 +32,7 @@ func TestCoffeesReturnsCoffees(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rw.Code)
 
-	bd := entities.Coffees{}
+	bd := []Coffee{}
 	err := json.Unmarshal(rw.Body.Bytes(), &bd)
 	assert.NoError(t, err)
 }
@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Coffee is the representation of a coffee in v1 of the api. The recipe
+// references the ingredient catalog by id.
+type Coffee struct {
+	ID          int                `json:"id"`
+	Name        string             `json:"name"`
+	Teaser      string             `json:"teaser"`
+	Description string             `json:"description"`
+	Price       float64            `json:"price"`
+	Image       string             `json:"image"`
+	CreatedAt   *time.Time         `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time         `json:"updated_at,omitempty"`
+	DeletedAt   *time.Time         `json:"deleted_at,omitempty"`
+	Ingredients []CoffeeIngredient `json:"ingredients"`
+}
+
+// CoffeeIngredient is an entry of the recipe of a Coffee
+type CoffeeIngredient struct {
+	IngredientID int    `json:"ingredient_id"`
+	Quantity     int    `json:"quantity"`
+	Unit         string `json:"unit"`
+}
+
+// NewCoffee maps a coffee entity to its v1 representation
+func NewCoffee(coffee entities.Coffee) Coffee {
+	ingredients := make([]CoffeeIngredient, len(coffee.Ingredients))
+	for n, ci := range coffee.Ingredients {
+		ingredients[n] = CoffeeIngredient{
+			IngredientID: ci.IngredientID,
+			Quantity:     ci.Quantity,
+			Unit:         ci.Unit,
+		}
+	}
+
+	return Coffee{
+		ID:          coffee.ID,
+		Name:        coffee.Name,
+		Teaser:      coffee.Teaser,
+		Description: coffee.Description,
+		Price:       coffee.Price,
+		Image:       coffee.Image,
+		CreatedAt:   entities.Timestamp(coffee.CreatedAt),
+		UpdatedAt:   entities.Timestamp(coffee.UpdatedAt),
+		DeletedAt:   entities.NullTimestamp(coffee.DeletedAt),
+		Ingredients: ingredients,
+	}
+}
@@ -2,23 +2,30 @@ package v2
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/hashicorp-demoapp/coffee-service/config"
 	"github.com/hashicorp-demoapp/coffee-service/data"
 	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/service/handler"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-func setupCoffeeHandler(t *testing.T) (*CoffeeService, *httptest.ResponseRecorder, *http.Request) {
+func setupCoffeeHandler(t *testing.T) (*handler.CoffeeService, *httptest.ResponseRecorder, *http.Request) {
 	c := &data.MockRepository{}
-	c.On("Find").Return(entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, nil)
+	c.On("Find", mock.Anything, mock.Anything).Return(&data.CoffeePage{Coffees: entities.Coffees{entities.Coffee{ID: 1, Name: "Test"}}, Total: 1}, nil)
+	c.On("Revision", mock.Anything).Return(time.Time{}, nil)
 
 	l := hclog.Default()
 
-	return &CoffeeService{c, l}, httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil)
+	return NewCoffeeService(c, l), httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil)
 }
 
 func TestCoffeesReturnsCoffees(t *testing.T) {
@@ -28,7 +35,32 @@ func TestCoffeesReturnsCoffees(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rw.Code)
 
-	bd := entities.Coffees{}
+	bd := []Coffee{}
 	err := json.Unmarshal(rw.Body.Bytes(), &bd)
 	assert.NoError(t, err)
 }
+
+func TestCoffeesFallBackToStalePageWhileCircuitIsOpen(t *testing.T) {
+	c := &data.MockRepository{}
+	menu := entities.Coffees{{ID: 1, Name: "Test", Ingredients: []entities.CoffeeIngredients{{IngredientID: 1, Quantity: 40, Unit: "ml", IngredientName: "Espresso"}}}}
+	c.On("Find", mock.Anything, mock.Anything).Return(&data.CoffeePage{Coffees: menu, Total: 1}, nil).Once()
+	c.On("Find", mock.Anything, mock.Anything).Return(nil, errors.New("connection reset by peer"))
+	c.On("Revision", mock.Anything).Return(time.Time{}, errors.New("connection reset by peer"))
+
+	breaker := data.NewCircuitBreaker(c, config.CircuitBreaker{FailureThreshold: 1, CoolDown: time.Hour, Fallback: true})
+	h := NewCoffeeService(breaker, hclog.NewNullLogger())
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees", nil))
+	require.Equal(t, data.BreakerOpen, breaker.State())
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, `110 - "Response is Stale"`, rw.Header().Get("Warning"))
+
+	bd := []Coffee{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &bd))
+	require.Len(t, bd, 1)
+	assert.Equal(t, "Espresso", bd[0].Ingredients[0].Name)
+}
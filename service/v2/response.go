@@ -0,0 +1,56 @@
+package v2
+
+import (
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Coffee is the representation of a coffee in v2 of the api. The recipe
+// embeds the names of the ingredients, so clients no longer look them up.
+type Coffee struct {
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Teaser      string       `json:"teaser"`
+	Description string       `json:"description"`
+	Price       float64      `json:"price"`
+	Image       string       `json:"image"`
+	CreatedAt   *time.Time   `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time   `json:"updated_at,omitempty"`
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty"`
+	Ingredients []Ingredient `json:"ingredients"`
+}
+
+// Ingredient is an ingredient of the recipe of a Coffee
+type Ingredient struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Unit     string `json:"unit"`
+}
+
+// NewCoffee maps a coffee entity to its v2 representation
+func NewCoffee(coffee entities.Coffee) Coffee {
+	ingredients := make([]Ingredient, len(coffee.Ingredients))
+	for n, ci := range coffee.Ingredients {
+		ingredients[n] = Ingredient{
+			ID:       ci.IngredientID,
+			Name:     ci.IngredientName,
+			Quantity: ci.Quantity,
+			Unit:     ci.Unit,
+		}
+	}
+
+	return Coffee{
+		ID:          coffee.ID,
+		Name:        coffee.Name,
+		Teaser:      coffee.Teaser,
+		Description: coffee.Description,
+		Price:       coffee.Price,
+		Image:       coffee.Image,
+		CreatedAt:   entities.Timestamp(coffee.CreatedAt),
+		UpdatedAt:   entities.Timestamp(coffee.UpdatedAt),
+		DeletedAt:   entities.NullTimestamp(coffee.DeletedAt),
+		Ingredients: ingredients,
+	}
+}
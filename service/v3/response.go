@@ -0,0 +1,75 @@
+package v3
+
+import (
+	"time"
+
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Currency is the currency of the coffee prices
+const Currency = "USD"
+
+// Coffee is the representation of a coffee in v3 of the api. On top of the
+// embedded ingredients of v2, the price carries its currency and the coffee
+// reports whether it can be ordered.
+type Coffee struct {
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Teaser      string       `json:"teaser"`
+	Description string       `json:"description"`
+	Price       Money        `json:"price"`
+	Image       string       `json:"image"`
+	CreatedAt   *time.Time   `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time   `json:"updated_at,omitempty"`
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty"`
+	Available   bool         `json:"available"`
+	Ingredients []Ingredient `json:"ingredients"`
+}
+
+// Money is an amount in a currency
+type Money struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// Ingredient is an ingredient of the recipe of a Coffee
+type Ingredient struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Unit     string `json:"unit"`
+}
+
+// NewCoffee maps a coffee entity to its v3 representation. A coffee is
+// available when neither it nor an ingredient of its recipe is deleted.
+func NewCoffee(coffee entities.Coffee) Coffee {
+	available := !coffee.DeletedAt.Valid
+
+	ingredients := make([]Ingredient, len(coffee.Ingredients))
+	for n, ci := range coffee.Ingredients {
+		if ci.IngredientDeletedAt.Valid {
+			available = false
+		}
+
+		ingredients[n] = Ingredient{
+			ID:       ci.IngredientID,
+			Name:     ci.IngredientName,
+			Quantity: ci.Quantity,
+			Unit:     ci.Unit,
+		}
+	}
+
+	return Coffee{
+		ID:          coffee.ID,
+		Name:        coffee.Name,
+		Teaser:      coffee.Teaser,
+		Description: coffee.Description,
+		Price:       Money{Amount: coffee.Price, Currency: Currency},
+		Image:       coffee.Image,
+		CreatedAt:   entities.Timestamp(coffee.CreatedAt),
+		UpdatedAt:   entities.Timestamp(coffee.UpdatedAt),
+		DeletedAt:   entities.NullTimestamp(coffee.DeletedAt),
+		Available:   available,
+		Ingredients: ingredients,
+	}
+}
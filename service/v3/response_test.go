@@ -0,0 +1,102 @@
+package v3
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+	"github.com/hashicorp-demoapp/coffee-service/service/handler"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenTime is the time the golden menu was created at
+var goldenTime = time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+
+// goldenMenu is the menu rendered in the golden files. The Connectaccino uses
+// an ingredient deleted from the catalog.
+var goldenMenu = entities.Coffees{
+	{
+		ID:        1,
+		Name:      "Packer Spiced Latte",
+		Teaser:    "Packed with goodness to spice up your images",
+		Price:     350,
+		Image:     "/packer.png",
+		CreatedAt: goldenTime,
+		UpdatedAt: goldenTime.Add(time.Hour),
+		Ingredients: []entities.CoffeeIngredients{
+			{IngredientID: 1, Quantity: 40, Unit: "ml", IngredientName: "Espresso"},
+			{IngredientID: 4, Quantity: 5, Unit: "g", IngredientName: "Pumpkin Spice"},
+		},
+	},
+	{
+		ID:        6,
+		Name:      "Connectaccino",
+		Teaser:    "Discover the wonders of our meshy service",
+		Price:     250,
+		Image:     "/consul.png",
+		CreatedAt: goldenTime,
+		UpdatedAt: goldenTime,
+		Ingredients: []entities.CoffeeIngredients{
+			{IngredientID: 1, Quantity: 40, Unit: "ml", IngredientName: "Espresso"},
+			{IngredientID: 5, Quantity: 300, Unit: "ml", IngredientName: "Steamed Milk", IngredientDeletedAt: sql.NullTime{Time: goldenTime.AddDate(0, 1, 0), Valid: true}},
+		},
+	},
+}
+
+// assertGolden compares the indented json body with the golden file, or
+// rewrites the golden file when the tests run with -update.
+func assertGolden(t *testing.T, name string, body []byte) {
+	indented := bytes.Buffer{}
+	require.NoError(t, json.Indent(&indented, body, "", "  "))
+	indented.WriteString("\n")
+
+	golden := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		require.NoError(t, ioutil.WriteFile(golden, indented.Bytes(), 0644))
+	}
+
+	expected, err := ioutil.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), indented.String())
+}
+
+func setupGoldenHandler(t *testing.T) *handler.CoffeeService {
+	c := &data.MockRepository{}
+	c.On("Find", mock.Anything, mock.Anything).Return(&data.CoffeePage{Coffees: goldenMenu, Total: len(goldenMenu)}, nil)
+	c.On("FindByID", mock.Anything, 1, mock.Anything).Return(&goldenMenu[0], nil)
+	c.On("Revision", mock.Anything).Return(goldenTime.Add(time.Hour), nil)
+
+	return NewCoffeeService(c, hclog.NewNullLogger())
+}
+
+func TestCoffeesMatchGoldenFile(t *testing.T) {
+	c := setupGoldenHandler(t)
+	rw := httptest.NewRecorder()
+
+	c.ServeHTTP(rw, httptest.NewRequest("GET", "/coffees", nil))
+
+	assertGolden(t, "coffees", rw.Body.Bytes())
+}
+
+func TestCoffeeMatchesGoldenFile(t *testing.T) {
+	c := setupGoldenHandler(t)
+	rw := httptest.NewRecorder()
+
+	c.GetCoffee(rw, mux.SetURLVars(httptest.NewRequest("GET", "/coffees/1", nil), map[string]string{"id": "1"}))
+
+	assertGolden(t, "coffee", rw.Body.Bytes())
+}
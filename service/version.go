@@ -0,0 +1,216 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/service/problem"
+	"github.com/hashicorp/go-hclog"
+)
+
+// APIVersionHeader is the request header selecting a version of the api, and
+// the response header reporting the version that served the request.
+const APIVersionHeader = "API-Version"
+
+// mediaTypeVersion matches the vendor media types selecting a version of the
+// api, e.g. application/vnd.coffee.v2+json.
+var mediaTypeVersion = regexp.MustCompile(`^application/vnd\.coffee\.(v[0-9]+)\+json$`)
+
+// NewCoffeeVersions is a factory method that returns the handler of every
+// version of the coffee api, all serving the coffees from the given repository.
+func NewCoffeeVersions(cfg *config.Config, repository data.Repository) (map[config.VersionKey]CoffeeAPI, error) {
+	versions := map[config.VersionKey]CoffeeAPI{}
+
+	for _, version := range config.Versions {
+		versionCfg := *cfg
+		versionCfg.Version = version
+
+		handler, err := NewCoffee(&versionCfg, repository)
+		if err != nil {
+			return nil, err
+		}
+
+		versions[version] = handler
+	}
+
+	return versions, nil
+}
+
+// RegisterCoffeeRoutes registers the routes of the coffee api on the router,
+// the GET responses carry the cacheControl header.
+func RegisterCoffeeRoutes(router *mux.Router, coffeeService CoffeeAPI, cacheControl string) {
+	cache := CacheControl(cacheControl)
+	router.Handle("/coffees", cache(coffeeService)).Methods("GET")
+	router.Handle("/coffees/{id:[0-9]+}", cache(http.HandlerFunc(coffeeService.GetCoffee))).Methods("GET")
+	router.Handle("/coffees/{id:[0-9]+}/ingredients", cache(http.HandlerFunc(coffeeService.GetCoffeeIngredients))).Methods("GET")
+	router.HandleFunc("/coffees", coffeeService.CreateCoffee).Methods("POST")
+	router.HandleFunc("/coffees/{id:[0-9]+}", coffeeService.UpdateCoffee).Methods("PUT")
+	router.HandleFunc("/coffees/{id:[0-9]+}", coffeeService.PatchCoffee).Methods("PATCH")
+	router.HandleFunc("/coffees/{id:[0-9]+}", coffeeService.DeleteCoffee).Methods("DELETE")
+	router.HandleFunc("/coffees/{id:[0-9]+}/restore", coffeeService.RestoreCoffee).Methods("POST")
+}
+
+// VersionHeaders is middleware reporting the version serving the requests, and
+// its deprecation when the version is deprecated.
+func VersionHeaders(cfg *config.Config, version config.VersionKey) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			writeVersionHeaders(rw, cfg, version)
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// VersionedCoffee serves every version of the coffee api from the same routes.
+// The version is chosen for each request from the API-Version header, then
+// from a vendor media type in the Accept header, e.g.
+// application/vnd.coffee.v2+json, falling back to the configured version.
+type VersionedCoffee struct {
+	cfg      *config.Config
+	versions map[config.VersionKey]CoffeeAPI
+	logger   hclog.Logger
+}
+
+// NewVersionedCoffee is a factory method that returns a VersionedCoffee
+// dispatching to the handlers of each version.
+func NewVersionedCoffee(cfg *config.Config, versions map[config.VersionKey]CoffeeAPI) (*VersionedCoffee, error) {
+	if _, ok := versions[cfg.Version]; !ok {
+		return nil, fmt.Errorf("no handler for the default version %s", cfg.Version)
+	}
+
+	return &VersionedCoffee{cfg, versions, cfg.Logger}, nil
+}
+
+// ServeHTTP handles incoming requests for the api coffees route
+func (v *VersionedCoffee) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	v.dispatch(rw, r, func(api CoffeeAPI) http.HandlerFunc { return api.ServeHTTP })
+}
+
+// GetCoffee handles incoming requests for the api coffees/{id} route
+func (v *VersionedCoffee) GetCoffee(rw http.ResponseWriter, r *http.Request) {
+	v.dispatch(rw, r, func(api CoffeeAPI) http.HandlerFunc { return api.GetCoffee })
+}
+
+// GetCoffeeIngredients handles incoming requests for the api coffees/{id}/ingredients route
+func (v *VersionedCoffee) GetCoffeeIngredients(rw http.ResponseWriter, r *http.Request) {
+	v.dispatch(rw, r, func(api CoffeeAPI) http.HandlerFunc { return api.GetCoffeeIngredients })
+}
+
+// CreateCoffee handles POST requests for the api coffees route
+func (v *VersionedCoffee) CreateCoffee(rw http.ResponseWriter, r *http.Request) {
+	v.dispatch(rw, r, func(api CoffeeAPI) http.HandlerFunc { return api.CreateCoffee })
+}
+
+// UpdateCoffee handles PUT requests for the api coffees/{id} route
+func (v *VersionedCoffee) UpdateCoffee(rw http.ResponseWriter, r *http.Request) {
+	v.dispatch(rw, r, func(api CoffeeAPI) http.HandlerFunc { return api.UpdateCoffee })
+}
+
+// PatchCoffee handles PATCH requests for the api coffees/{id} route
+func (v *VersionedCoffee) PatchCoffee(rw http.ResponseWriter, r *http.Request) {
+	v.dispatch(rw, r, func(api CoffeeAPI) http.HandlerFunc { return api.PatchCoffee })
+}
+
+// DeleteCoffee handles DELETE requests for the api coffees/{id} route
+func (v *VersionedCoffee) DeleteCoffee(rw http.ResponseWriter, r *http.Request) {
+	v.dispatch(rw, r, func(api CoffeeAPI) http.HandlerFunc { return api.DeleteCoffee })
+}
+
+// RestoreCoffee handles POST requests for the api coffees/{id}/restore route
+func (v *VersionedCoffee) RestoreCoffee(rw http.ResponseWriter, r *http.Request) {
+	v.dispatch(rw, r, func(api CoffeeAPI) http.HandlerFunc { return api.RestoreCoffee })
+}
+
+// dispatch negotiates the version of the request and serves it with the
+// handler selected from that version.
+func (v *VersionedCoffee) dispatch(rw http.ResponseWriter, r *http.Request, handler func(CoffeeAPI) http.HandlerFunc) {
+	// The response depends on the negotiation headers, caches must key on them
+	rw.Header().Set("Vary", "Accept, "+APIVersionHeader)
+
+	version, status, err := v.negotiate(r)
+	if err != nil {
+		v.logger.Debug("Unable to negotiate api version", "error", err)
+		problem.Write(rw, r, problem.New(status, err.Error()))
+		return
+	}
+
+	writeVersionHeaders(rw, v.cfg, version)
+	handler(v.versions[version])(rw, r)
+}
+
+// negotiate returns the version requested by r, or the status code and error
+// to respond with when the requested version is not served.
+func (v *VersionedCoffee) negotiate(r *http.Request) (config.VersionKey, int, error) {
+	if requested := r.Header.Get(APIVersionHeader); requested != "" {
+		version := parseVersion(requested)
+		if _, ok := v.versions[version]; !ok {
+			return "", http.StatusBadRequest, fmt.Errorf("unsupported %s %q", APIVersionHeader, requested)
+		}
+
+		return version, 0, nil
+	}
+
+	requested := false
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0])
+
+			match := mediaTypeVersion.FindStringSubmatch(strings.ToLower(mediaType))
+			if match == nil {
+				continue
+			}
+
+			requested = true
+			if version := config.VersionKeyFromString(match[1]); v.versions[version] != nil {
+				return version, 0, nil
+			}
+		}
+	}
+
+	if requested {
+		return "", http.StatusNotAcceptable, fmt.Errorf("unsupported media type version, expected one of %s", strings.Join(mediaTypes(), ", "))
+	}
+
+	return v.cfg.Version, 0, nil
+}
+
+// parseVersion parses an API-Version header value, accepting both v2 and 2
+func parseVersion(value string) config.VersionKey {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if !strings.HasPrefix(value, "v") {
+		value = "v" + value
+	}
+
+	return config.VersionKeyFromString(value)
+}
+
+// mediaTypes returns the vendor media types of every version
+func mediaTypes() []string {
+	types := make([]string, len(config.Versions))
+	for n, version := range config.Versions {
+		types[n] = fmt.Sprintf("application/vnd.coffee.%s+json", version)
+	}
+
+	return types
+}
+
+// writeVersionHeaders reports the version serving the request, and when the
+// version is deprecated the Deprecation and Sunset headers of RFC 8594.
+func writeVersionHeaders(rw http.ResponseWriter, cfg *config.Config, version config.VersionKey) {
+	rw.Header().Set(APIVersionHeader, version.String())
+
+	deprecation, ok := cfg.Deprecations[version]
+	if !ok {
+		return
+	}
+
+	rw.Header().Set("Deprecation", "true")
+	if !deprecation.Sunset.IsZero() {
+		rw.Header().Set("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// versionStub is a CoffeeAPI answering every request with its version
+type versionStub string
+
+func (v versionStub) ServeHTTP(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte(v)) }
+func (v versionStub) GetCoffee(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte(v)) }
+func (v versionStub) GetCoffeeIngredients(rw http.ResponseWriter, r *http.Request) {
+	rw.Write([]byte(v))
+}
+func (v versionStub) CreateCoffee(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte(v)) }
+func (v versionStub) UpdateCoffee(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte(v)) }
+func (v versionStub) PatchCoffee(rw http.ResponseWriter, r *http.Request)  { rw.Write([]byte(v)) }
+func (v versionStub) DeleteCoffee(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte(v)) }
+func (v versionStub) RestoreCoffee(rw http.ResponseWriter, r *http.Request) {
+	rw.Write([]byte(v))
+}
+
+func setupVersionRouter(t *testing.T) *mux.Router {
+	cfg := &config.Config{
+		Logger:  hclog.NewNullLogger(),
+		Version: config.V2,
+		Deprecations: map[config.VersionKey]config.Deprecation{
+			config.V1: {Sunset: time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	versions := map[config.VersionKey]CoffeeAPI{
+		config.V1: versionStub("v1"),
+		config.V2: versionStub("v2"),
+		config.V3: versionStub("v3"),
+	}
+
+	versioned, err := NewVersionedCoffee(cfg, versions)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	for _, version := range config.Versions {
+		versionRouter := router.PathPrefix("/" + version.String()).Subrouter()
+		versionRouter.Use(VersionHeaders(cfg, version))
+		RegisterCoffeeRoutes(versionRouter, versions[version], "")
+	}
+	RegisterCoffeeRoutes(router, versioned, "")
+
+	return router
+}
+
+func serveVersion(router *mux.Router, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, r)
+
+	return rw
+}
+
+func TestVersionPrefixSelectsVersion(t *testing.T) {
+	router := setupVersionRouter(t)
+
+	for _, version := range []string{"v1", "v2", "v3"} {
+		rw := serveVersion(router, "GET", "/"+version+"/coffees/1", nil)
+
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, version, rw.Body.String())
+		assert.Equal(t, version, rw.Header().Get(APIVersionHeader))
+	}
+}
+
+func TestUnversionedRouteServesDefaultVersion(t *testing.T) {
+	rw := serveVersion(setupVersionRouter(t), "GET", "/coffees", nil)
+
+	assert.Equal(t, "v2", rw.Body.String())
+	assert.Equal(t, "v2", rw.Header().Get(APIVersionHeader))
+	assert.Equal(t, "Accept, API-Version", rw.Header().Get("Vary"))
+}
+
+func TestAPIVersionHeaderSelectsVersion(t *testing.T) {
+	router := setupVersionRouter(t)
+
+	assert.Equal(t, "v3", serveVersion(router, "POST", "/coffees", map[string]string{"API-Version": "v3"}).Body.String())
+	assert.Equal(t, "v1", serveVersion(router, "DELETE", "/coffees/1", map[string]string{"API-Version": "1"}).Body.String())
+}
+
+func TestAPIVersionHeaderTakesPrecedenceOverAccept(t *testing.T) {
+	rw := serveVersion(setupVersionRouter(t), "GET", "/coffees", map[string]string{
+		"API-Version": "v1",
+		"Accept":      "application/vnd.coffee.v3+json",
+	})
+
+	assert.Equal(t, "v1", rw.Body.String())
+}
+
+func TestUnknownAPIVersionHeaderReturnsBadRequest(t *testing.T) {
+	rw := serveVersion(setupVersionRouter(t), "GET", "/coffees", map[string]string{"API-Version": "v9"})
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestAcceptMediaTypeSelectsVersion(t *testing.T) {
+	rw := serveVersion(setupVersionRouter(t), "GET", "/coffees/1/ingredients", map[string]string{
+		"Accept": "text/html, application/vnd.coffee.v3+json; q=0.9",
+	})
+
+	assert.Equal(t, "v3", rw.Body.String())
+}
+
+func TestAcceptWithoutVendorMediaTypeServesDefaultVersion(t *testing.T) {
+	rw := serveVersion(setupVersionRouter(t), "GET", "/coffees", map[string]string{"Accept": "application/json"})
+
+	assert.Equal(t, "v2", rw.Body.String())
+}
+
+func TestUnknownAcceptMediaTypeVersionReturnsNotAcceptable(t *testing.T) {
+	rw := serveVersion(setupVersionRouter(t), "GET", "/coffees", map[string]string{"Accept": "application/vnd.coffee.v9+json"})
+
+	assert.Equal(t, http.StatusNotAcceptable, rw.Code)
+}
+
+func TestDeprecatedVersionReturnsDeprecationHeaders(t *testing.T) {
+	router := setupVersionRouter(t)
+
+	for _, rw := range []*httptest.ResponseRecorder{
+		serveVersion(router, "GET", "/v1/coffees", nil),
+		serveVersion(router, "GET", "/coffees", map[string]string{"API-Version": "v1"}),
+	} {
+		assert.Equal(t, "true", rw.Header().Get("Deprecation"))
+		assert.Equal(t, "Wed, 30 Jun 2021 00:00:00 GMT", rw.Header().Get("Sunset"))
+	}
+
+	rw := serveVersion(router, "GET", "/v3/coffees", nil)
+	assert.Empty(t, rw.Header().Get("Deprecation"))
+	assert.Empty(t, rw.Header().Get("Sunset"))
+}
+
+func TestNewVersionedCoffeeReturnsErrorWithoutDefaultVersion(t *testing.T) {
+	_, err := NewVersionedCoffee(&config.Config{Logger: hclog.NewNullLogger(), Version: config.V3}, map[config.VersionKey]CoffeeAPI{
+		config.V1: versionStub("v1"),
+	})
+
+	assert.Error(t, err)
+}
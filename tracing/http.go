@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// versionHeader is the response header reporting the API version that served
+// the request.
+const versionHeader = "API-Version"
+
+// apiVersionKey is the attribute of the API version serving the request
+const apiVersionKey = attribute.Key("coffee.api_version")
+
+// Middleware starts a server span for every request, continuing the trace of
+// the caller when the request carries a traceparent or b3 header. The span is
+// named after the route template so that ids do not create new names.
+func (t *Tracing) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := ""
+		name := fmt.Sprintf("HTTP %s", r.Method)
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+				name = template
+			}
+		}
+
+		ctx, span := t.tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", route, r)...),
+		)
+		defer span.End()
+
+		if t.logger.IsTrace() {
+			spanCtx := span.SpanContext()
+			t.logger.Trace("Span context", "route", name, "trace_id", spanCtx.TraceID(), "span_id", spanCtx.SpanID())
+		}
+
+		recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		if version := rw.Header().Get(versionHeader); version != "" {
+			span.SetAttributes(apiVersionKey.String(version))
+		}
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(recorder.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(recorder.status))
+	})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code before writing it
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 status of handlers not calling WriteHeader
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRouter(tr *Tracing) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(tr.Middleware)
+	router.HandleFunc("/coffees/{id:[0-9]+}", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set(versionHeader, "v2")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	return router
+}
+
+func TestMiddlewareStartsServerSpanNamedAfterRoute(t *testing.T) {
+	tr, exporter := setupTracing(t)
+
+	setupRouter(tr).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/coffees/1", nil))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "/coffees/{id:[0-9]+}", spans[0].Name)
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Contains(t, spans[0].Attributes, attribute.Int("http.status_code", http.StatusServiceUnavailable))
+	assert.Contains(t, spans[0].Attributes, apiVersionKey.String("v2"))
+}
+
+func TestMiddlewareContinuesTraceparent(t *testing.T) {
+	tr, exporter := setupTracing(t)
+	r := httptest.NewRequest("GET", "/coffees/1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	setupRouter(tr).ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+}
+
+func TestMiddlewareLogsSpanContextAtTraceLevel(t *testing.T) {
+	tr, _ := setupTracing(t)
+	var out bytes.Buffer
+	tr.logger = hclog.New(&hclog.LoggerOptions{Output: &out, Level: hclog.Trace})
+	r := httptest.NewRequest("GET", "/coffees/1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	setupRouter(tr).ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Contains(t, out.String(), "Span context")
+	assert.Contains(t, out.String(), "trace_id=4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+func TestMiddlewareContinuesB3(t *testing.T) {
+	tr, exporter := setupTracing(t)
+	r := httptest.NewRequest("GET", "/coffees/1", nil)
+	r.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+	r.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+	r.Header.Set("X-B3-Sampled", "1")
+
+	setupRouter(tr).ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "463ac35c9f6413ad48485a3953bb6124", spans[0].SpanContext.TraceID().String())
+	assert.Equal(t, "a2fb4a1d1a96d312", spans[0].Parent.SpanID().String())
+}
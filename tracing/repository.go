@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+// Repository is a data.Repository decorator creating a span, child of the
+// request span, for every query made to the wrapped repository.
+type Repository struct {
+	next   data.Repository
+	system attribute.KeyValue
+	tracer trace.Tracer
+}
+
+// NewRepository wraps the repository of the backend with spans
+func (t *Tracing) NewRepository(next data.Repository, backend config.BackendKey) *Repository {
+	system := semconv.DBSystemKey.String(backend.String())
+	switch backend {
+	case config.Postgres:
+		system = semconv.DBSystemPostgreSQL
+	case config.SQLite:
+		system = semconv.DBSystemSqlite
+	}
+
+	return &Repository{next, system, t.tracer}
+}
+
+// start starts the span of the operation
+func (r *Repository) start(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return r.tracer.Start(ctx, "repository."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(r.system, semconv.DBOperationKey.String(operation)),
+	)
+}
+
+// end ends the span, errors describing the request rather than a failure of
+// the database are recorded without failing the span.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !data.IsRequestError(err) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+
+	span.End()
+}
+
+// Find implements data.Repository
+func (r *Repository) Find(ctx context.Context, query data.CoffeeQuery) (page *data.CoffeePage, err error) {
+	ctx, span := r.start(ctx, "find")
+	defer func() { end(span, err) }()
+	return r.next.Find(ctx, query)
+}
+
+// FindByID implements data.Repository
+func (r *Repository) FindByID(ctx context.Context, id int, includeDeleted bool) (coffee *entities.Coffee, err error) {
+	ctx, span := r.start(ctx, "find_by_id")
+	defer func() { end(span, err) }()
+	return r.next.FindByID(ctx, id, includeDeleted)
+}
+
+// FindIngredients implements data.Repository
+func (r *Repository) FindIngredients(ctx context.Context, coffeeID int) (ingredients entities.Ingredients, err error) {
+	ctx, span := r.start(ctx, "find_ingredients")
+	defer func() { end(span, err) }()
+	return r.next.FindIngredients(ctx, coffeeID)
+}
+
+// CreateCoffee implements data.Repository
+func (r *Repository) CreateCoffee(ctx context.Context, coffee entities.Coffee) (created *entities.Coffee, err error) {
+	ctx, span := r.start(ctx, "create_coffee")
+	defer func() { end(span, err) }()
+	return r.next.CreateCoffee(ctx, coffee)
+}
+
+// UpdateCoffee implements data.Repository
+func (r *Repository) UpdateCoffee(ctx context.Context, coffee entities.Coffee) (updated *entities.Coffee, err error) {
+	ctx, span := r.start(ctx, "update_coffee")
+	defer func() { end(span, err) }()
+	return r.next.UpdateCoffee(ctx, coffee)
+}
+
+// DeleteCoffee implements data.Repository
+func (r *Repository) DeleteCoffee(ctx context.Context, id int) (err error) {
+	ctx, span := r.start(ctx, "delete_coffee")
+	defer func() { end(span, err) }()
+	return r.next.DeleteCoffee(ctx, id)
+}
+
+// RestoreCoffee implements data.Repository
+func (r *Repository) RestoreCoffee(ctx context.Context, id int) (coffee *entities.Coffee, err error) {
+	ctx, span := r.start(ctx, "restore_coffee")
+	defer func() { end(span, err) }()
+	return r.next.RestoreCoffee(ctx, id)
+}
+
+// Purge implements data.Repository
+func (r *Repository) Purge(ctx context.Context, before time.Time) (purged int, err error) {
+	ctx, span := r.start(ctx, "purge")
+	defer func() { end(span, err) }()
+	return r.next.Purge(ctx, before)
+}
+
+// Revision implements data.Repository
+func (r *Repository) Revision(ctx context.Context) (revision time.Time, err error) {
+	ctx, span := r.start(ctx, "revision")
+	defer func() { end(span, err) }()
+	return r.next.Revision(ctx)
+}
+
+// ListIngredients implements data.Repository
+func (r *Repository) ListIngredients(ctx context.Context, query data.IngredientQuery) (ingredients entities.Ingredients, err error) {
+	ctx, span := r.start(ctx, "list_ingredients")
+	defer func() { end(span, err) }()
+	return r.next.ListIngredients(ctx, query)
+}
+
+// FindIngredientByID implements data.Repository
+func (r *Repository) FindIngredientByID(ctx context.Context, id int, includeDeleted bool) (ingredient *entities.Ingredient, err error) {
+	ctx, span := r.start(ctx, "find_ingredient_by_id")
+	defer func() { end(span, err) }()
+	return r.next.FindIngredientByID(ctx, id, includeDeleted)
+}
+
+// CreateIngredient implements data.Repository
+func (r *Repository) CreateIngredient(ctx context.Context, ingredient entities.Ingredient) (created *entities.Ingredient, err error) {
+	ctx, span := r.start(ctx, "create_ingredient")
+	defer func() { end(span, err) }()
+	return r.next.CreateIngredient(ctx, ingredient)
+}
+
+// UpdateIngredient implements data.Repository
+func (r *Repository) UpdateIngredient(ctx context.Context, ingredient entities.Ingredient) (updated *entities.Ingredient, err error) {
+	ctx, span := r.start(ctx, "update_ingredient")
+	defer func() { end(span, err) }()
+	return r.next.UpdateIngredient(ctx, ingredient)
+}
+
+// DeleteIngredient implements data.Repository
+func (r *Repository) DeleteIngredient(ctx context.Context, id int) (err error) {
+	ctx, span := r.start(ctx, "delete_ingredient")
+	defer func() { end(span, err) }()
+	return r.next.DeleteIngredient(ctx, id)
+}
+
+// Ping implements data.Repository
+func (r *Repository) Ping(ctx context.Context) (err error) {
+	ctx, span := r.start(ctx, "ping")
+	defer func() { end(span, err) }()
+	return r.next.Ping(ctx)
+}
+
+// Close implements data.Repository
+func (r *Repository) Close() error {
+	return r.next.Close()
+}
@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+	"github.com/hashicorp-demoapp/coffee-service/data"
+	"github.com/hashicorp-demoapp/coffee-service/data/entities"
+)
+
+func TestRepositoryCreatesChildSpanForQuery(t *testing.T) {
+	tr, exporter := setupTracing(t)
+	mr := &data.MockRepository{}
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(&entities.Coffee{ID: 1}, nil)
+
+	ctx, parent := tr.tracer.Start(context.Background(), "request")
+	_, err := tr.NewRepository(mr, config.Postgres).FindByID(ctx, 1, false)
+	parent.End()
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "repository.find_by_id", spans[0].Name)
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Contains(t, spans[0].Attributes, semconv.DBSystemPostgreSQL)
+}
+
+func TestRepositoryFailsSpanOnUnexpectedErrorsOnly(t *testing.T) {
+	tr, exporter := setupTracing(t)
+	mr := &data.MockRepository{}
+	mr.On("FindByID", mock.Anything, 1, mock.Anything).Return(nil, errors.New("connection reset"))
+	mr.On("FindByID", mock.Anything, 2, mock.Anything).Return(nil, data.ErrNotFound)
+	r := tr.NewRepository(mr, config.Memory)
+
+	r.FindByID(context.Background(), 1, false)
+	r.FindByID(context.Background(), 2, false)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+	assert.Len(t, spans[1].Events, 1)
+}
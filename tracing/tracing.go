@@ -0,0 +1,90 @@
+// Package tracing configures OpenTelemetry for the service: the tracer
+// provider exporting to an OTLP collector, the W3C trace context and B3
+// propagation, server spans for the HTTP requests and spans for the
+// repository queries.
+package tracing
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/hashicorp/go-hclog"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hashicorp-demoapp/coffee-service/config"
+)
+
+// instrumentationName identifies the tracer of the service
+const instrumentationName = "github.com/hashicorp-demoapp/coffee-service"
+
+// defaultTracesPath is the path of the OTLP/HTTP traces endpoint, appended to
+// an endpoint without a path
+const defaultTracesPath = "/v1/traces"
+
+// NewProvider creates the tracer provider of the service. Spans are exported
+// in batches to the OTLP/HTTP endpoint of the configuration, when there is one.
+// The provider must be shut down to flush the remaining spans.
+func NewProvider(ctx context.Context, cfg config.Tracing) (*sdktrace.TracerProvider, error) {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+
+	if cfg.Endpoint != "" {
+		exporter, err := otlptracehttp.New(ctx, exporterOptions(cfg.Endpoint)...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// exporterOptions translates the endpoint URL, already validated by the
+// configuration, to the options of the OTLP/HTTP exporter.
+func exporterOptions(endpoint string) []otlptracehttp.Option {
+	u, _ := url.Parse(endpoint)
+
+	path := u.Path
+	if path == "" || path == "/" {
+		path = defaultTracesPath
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host), otlptracehttp.WithURLPath(path)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	return opts
+}
+
+// NewPropagator returns the propagator of the trace context, reading and
+// writing both the W3C traceparent and the B3 headers.
+func NewPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+		b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader|b3.B3SingleHeader)),
+	)
+}
+
+// Tracing creates the spans of the service
+type Tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	logger     hclog.Logger
+}
+
+// New creates the spans of the service with the provider, extracting the
+// context of the callers with the propagator. The span context of every
+// request is logged at trace level.
+func New(provider trace.TracerProvider, propagator propagation.TextMapPropagator, logger hclog.Logger) *Tracing {
+	return &Tracing{provider.Tracer(instrumentationName), propagator, logger}
+}
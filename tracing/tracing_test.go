@@ -0,0 +1,22 @@
+package tracing
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupTracing(t *testing.T) (*Tracing, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	return New(provider, NewPropagator(), hclog.NewNullLogger()), exporter
+}
+
+func TestExporterOptionsDefaultToTracesPath(t *testing.T) {
+	assert.Len(t, exporterOptions("http://collector:4318"), 3)
+	assert.Len(t, exporterOptions("https://collector:4318/custom/traces"), 2)
+}
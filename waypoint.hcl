@@ -18,7 +18,7 @@ app "coffee-service" {
 
   deploy {
     use "kubernetes" {
-      probe_path = "/health"
+      probe_path = "/health/live"
       service_port = 9090
       annotations = {
         "prometheus.io/scrape" = "true",
@@ -32,7 +32,8 @@ app "coffee-service" {
         LOG_LEVEL = "INFO",
         BIND_ADDRESS = "localhost:9090",
         METRICS_ADDRESS = "localhost:9102",
-        VERSION = "v3"
+        VERSION = "v3",
+        DB_BACKEND = "memory"
       }
     }
   }